@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/crypt"
+)
+
+// list 子命令：列出 tar 归档中的条目
+//
+//	qzipgo list [-keys file] [-software] archive
+func runList(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	keys := flags.String("keys", "", "key file for encrypted archives")
+	software := flags.Bool("software", false, "decompress gzip, gzipext and lz4s archives in Go instead of qzip")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo list [-keys file] [-software] archive")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if err := configureArchiveClient(*keys, *software); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	entries, err := pkg.ListArchive(flags.Arg(0))
+	for _, entry := range entries {
+		fmt.Printf("%s %d/%d %10d %s %s", entry.Mode, entry.Uid, entry.Gid, entry.Size, entry.ModTime.Format("2006-01-02 15:04"), entry.Name)
+		if entry.Linkname != "" {
+			fmt.Printf(" -> %s", entry.Linkname)
+		}
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// extract 子命令：只解压 tar 归档中匹配的条目
+//
+//	qzipgo extract [-C dir] [-keys file] [-software] archive pattern...
+func runExtract(args []string) int {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	dir := flags.String("C", "", "destination directory (default: the directory of the archive)")
+	keys := flags.String("keys", "", "key file for encrypted archives")
+	software := flags.Bool("software", false, "decompress gzip, gzipext and lz4s archives in Go instead of qzip")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo extract [-C dir] [-keys file] [-software] archive pattern...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	if err := configureArchiveClient(*keys, *software); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report, err := pkg.DefaultClient.ExtractEntries(flags.Arg(0), flags.Args()[1:], *dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, result := range report.Results {
+		fmt.Printf("%s: extracted %d bytes into %s\n", result.Input, result.BytesOut, result.Output)
+	}
+	return 0
+}
+
+// 设置解密密钥与 Software 模式
+func configureArchiveClient(keyFile string, software bool) error {
+	pkg.DefaultClient.Software = software
+	if keyFile == "" {
+		return nil
+	}
+	keys, err := crypt.LoadKeyFile(keyFile)
+	if err != nil {
+		return err
+	}
+	pkg.DefaultClient.Encryption = &pkg.EncryptionOptions{Keys: keys}
+	return nil
+}
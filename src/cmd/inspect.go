@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// inspect 子命令：输出压缩文件的结构信息
+//
+//	qzipgo inspect [-json] file...
+func runInspect(args []string) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the inspections as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo inspect [-json] file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	status := 0
+	var inspections []*pkg.Inspection
+	for _, path := range flags.Args() {
+		inspection, err := pkg.Inspect(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+		if inspection == nil {
+			continue
+		}
+		if *asJSON {
+			inspections = append(inspections, inspection)
+		} else {
+			printInspection(os.Stdout, inspection)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(inspections); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+// 以文本形式输出结构信息，每个成员或数据块一行
+func printInspection(w io.Writer, i *pkg.Inspection) {
+	fmt.Fprintf(w, "%s: format=%s size=%d", i.Path, i.Format, i.Size)
+	if i.UncompressedSize >= 0 {
+		fmt.Fprintf(w, " uncompressed=%d", i.UncompressedSize)
+	}
+	fmt.Fprintln(w)
+	if len(i.Members) > 0 {
+		fmt.Fprintf(w, "members: %d\n", len(i.Members))
+	}
+	for n, m := range i.Members {
+		fmt.Fprintf(w, "  member %d offset=%d header=%d os=%d crc32=%08x isize=%d", n, m.Offset, m.HeaderSize, m.OS, m.CRC32, m.ISize)
+		if m.QZ {
+			fmt.Fprintf(w, " chunk=%d block=%d", m.ChunkSize, m.BlockSize)
+		} else {
+			fmt.Fprintf(w, " deflate=%d", m.BlockSize)
+		}
+		if m.Name != "" {
+			fmt.Fprintf(w, " name=%q", m.Name)
+		}
+		if !m.ModTime.IsZero() {
+			fmt.Fprintf(w, " mtime=%s", m.ModTime.UTC().Format("2006-01-02T15:04:05Z"))
+		}
+		if m.Comment != "" {
+			fmt.Fprintf(w, " comment=%q", m.Comment)
+		}
+		fmt.Fprintln(w)
+	}
+	for n, frame := range i.Frames {
+		fmt.Fprintf(w, "frame %d offset=%d blocks=%d max-block=%d independent=%t block-checksum=%t content-checksum=%t",
+			n, frame.Offset, frame.Blocks, frame.BlockMaxSize, frame.BlockIndependent, frame.BlockChecksum, frame.ContentChecksum)
+		if frame.HasContentSize {
+			fmt.Fprintf(w, " content-size=%d", frame.ContentSize)
+		}
+		if frame.HasDictID {
+			fmt.Fprintf(w, " dict-id=%d", frame.DictID)
+		}
+		fmt.Fprintln(w)
+		for _, block := range i.Blocks {
+			if block.Frame == n {
+				fmt.Fprintf(w, "  block offset=%d size=%d stored=%t\n", block.Offset, block.CompressedSize, block.Stored)
+			}
+		}
+	}
+}
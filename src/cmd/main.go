@@ -3,12 +3,34 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 
 	"github.com/ordinary-xiyv/qzipgo/src/internal"
 	"github.com/ordinary-xiyv/qzipgo/src/pkg"
 )
 
+// 子命令：参数为子命令之后的命令行参数，返回进程退出码
+var commands = map[string]func(args []string) int{
+	"inspect":          runInspect,
+	"test":             runTest,
+	"verify-manifest":  runVerifyManifest,
+	"sign":             runSign,
+	"verify-signature": runVerifySignature,
+	"list":             runList,
+	"extract":          runExtract,
+}
+
 func main() {
+	// 命令行工具将日志输出到标准错误
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	pkg.DefaultClient.Logger = logger
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 
 	fmt.Println("=========================")
 	// 检查是否qat是否可用
@@ -26,6 +48,7 @@ func main() {
 
 	cmd := internal.GetDefaultQzipCommand()
 	cmd.KeepSource = true
+	cmd.Logger = logger
 
 	cmd.InputFile = append(cmd.InputFile, "/tmp/test.txt")
 
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// verify-manifest 子命令：根据校验清单校验目录或 tar 归档
+//
+//	qzipgo verify-manifest [-software] manifest [directory|archive]
+func runVerifyManifest(args []string) int {
+	flags := flag.NewFlagSet("verify-manifest", flag.ContinueOnError)
+	software := flags.Bool("software", false, "decompress archives in Go instead of qzip")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo verify-manifest [-software] manifest [directory|archive]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return 2
+	}
+	pkg.DefaultClient.Software = *software
+	report, err := pkg.VerifyManifest(flags.Arg(0), flags.Arg(1))
+	if report == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, file := range report.Succeeded {
+		fmt.Printf("%s: OK\n", file)
+	}
+	for _, file := range report.FailedFiles() {
+		fmt.Fprintf(os.Stderr, "%s: FAILED: %s\n", file, report.Failed[file])
+	}
+	if err != nil {
+		if len(report.Failed) == 0 {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// sign 子命令：使用 ed25519 私钥为文件生成 .sig 分离签名
+//
+//	qzipgo sign -key private.pem file...
+func runSign(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "ed25519 private key (PKCS #8 PEM)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo sign -key private.pem file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyPath == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	key, err := pkg.LoadPrivateKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, file := range flags.Args() {
+		sigPath, err := pkg.SignFile(file, key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		fmt.Println(sigPath)
+	}
+	return status
+}
+
+// verify-signature 子命令：使用 ed25519 公钥验证文件的 .sig 分离签名
+//
+//	qzipgo verify-signature -key public.pem file...
+func runVerifySignature(args []string) int {
+	flags := flag.NewFlagSet("verify-signature", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "ed25519 public key (PKIX PEM)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo verify-signature -key public.pem file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyPath == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	key, err := pkg.LoadPublicKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, file := range flags.Args() {
+		if err := pkg.VerifyFile(file, key); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: OK\n", file)
+	}
+	return status
+}
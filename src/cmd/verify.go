@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// test 子命令：校验压缩文件与归档的完整性，目录中的压缩文件都会被校验
+//
+//	qzipgo test [-software] path...
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	software := flags.Bool("software", false, "decompress gzip, gzipext and lz4s files in Go instead of qzip")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: qzipgo test [-software] path...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	pkg.DefaultClient.Software = *software
+	report, err := pkg.Verify(pkg.BatchOptions{ContinueOnError: true}, flags.Args()...)
+	for _, result := range report.Results {
+		fmt.Printf("%s: OK (%d bytes)\n", result.Input, result.BytesOut)
+	}
+	for _, file := range report.FailedFiles() {
+		fmt.Fprintln(os.Stderr, report.Failed[file])
+	}
+	if err != nil {
+		if len(report.Failed) == 0 {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package internal
+
+// 参数列表中每个参数额外占用的字节数：结尾的 '\0' 以及 argv/envp 中的指针
+const argOverhead = 1 + 8
+
+// 预留给动态链接器、辅助向量等的空间，避免恰好卡在系统限制上
+const argReserve = 4 * 1024
+
+// 将输入文件拆分为多组，每组与固定参数、环境变量一起不超过 limit 字节（limit <= 0 时使用系统限制 ArgMax）
+//
+// 单个文件本身超过限制时单独成组，由 qzip 返回错误
+func (q QzipCommand) SplitInputFiles(limit int) [][]string {
+	if limit <= 0 {
+		limit = ArgMax()
+	}
+	// 构建不含输入文件的命令，得到固定参数
+	probe := q
+	probe.Options = append([]string(nil), q.Options...)
+	probe.InputFile = nil
+	fixed := probe.BuildQzipCommand()
+	budget := limit - argReserve - argsSize(fixed.Args) - argsSize(fixed.Env)
+
+	var groups [][]string
+	var group []string
+	size := 0
+	for _, file := range q.InputFile {
+		n := len(file) + argOverhead
+		if len(group) > 0 && size+n > budget {
+			groups = append(groups, group)
+			group, size = nil, 0
+		}
+		group = append(group, file)
+		size += n
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// 参数或环境变量占用的字节数
+func argsSize(args []string) int {
+	size := 0
+	for _, arg := range args {
+		size += len(arg) + argOverhead
+	}
+	return size
+}
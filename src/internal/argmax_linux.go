@@ -0,0 +1,25 @@
+package internal
+
+import "syscall"
+
+// Linux 内核对 argv+envp 的限制为栈大小限制的 1/4，且不小于 128KiB、不大于 6MiB（_STK_LIM 的 3/4）
+const (
+	minArgMax = 128 * 1024
+	maxArgMax = 6 * 1024 * 1024
+)
+
+// 获取系统对命令行参数与环境变量总长度的限制
+func ArgMax() int {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_STACK, &rlimit); err != nil {
+		return minArgMax
+	}
+	// 栈大小不受限制（RLIM_INFINITY）时同样使用上限
+	if rlimit.Cur/4 > maxArgMax {
+		return maxArgMax
+	}
+	if rlimit.Cur/4 < minArgMax {
+		return minArgMax
+	}
+	return int(rlimit.Cur / 4)
+}
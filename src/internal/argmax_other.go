@@ -0,0 +1,10 @@
+//go:build !linux
+
+package internal
+
+// 获取系统对命令行参数与环境变量总长度的限制
+//
+// 非 Linux 系统使用保守的 256KiB（macOS 为 1MiB，BSD 通常为 256KiB）
+func ArgMax() int {
+	return 256 * 1024
+}
@@ -2,24 +2,20 @@ package internal
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
+	"log/slog"
 	"regexp"
 )
 
-const (
-	// ANSI 转义码设置绿色
-	greenBold = "\033[1;32m"
-	reset     = "\033[0m"
-)
-
 type QatService struct {
 	IcpRoot         *string
 	QzRoot          *string
 	Hardwareses     []*Hardwarese
 	TarIsAvailable  bool
 	QzipIsAvailable bool
+	// 检查时使用的子进程执行配置
+	Exec ExecConfig
+	// 日志输出，为 nil 时不输出任何日志
+	Logger *slog.Logger
 }
 
 type Hardwarese struct {
@@ -30,28 +26,28 @@ type Hardwarese struct {
 
 func CheckQzipIsAvailable(qat *QatService) bool {
 	// 检查 qzip 版本
-	cmd := exec.Command("qzip", "--version")
+	cmd := qat.Exec.Command(qat.Exec.Qzip(), "--version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("qzip 未安装或无法执行: %v\n", err)
+		LoggerOf(qat.Logger).Error("qzip 未安装或无法执行", "command", cmd.String(), "error", err)
 		qat.QzipIsAvailable = false
 		return false
 	}
-	fmt.Printf("qzip 版本信息:\n%s\n", output)
+	LoggerOf(qat.Logger).Info("qzip 版本信息", "command", cmd.String(), "output", string(output))
 	qat.QzipIsAvailable = true
 	return true
 }
 
 func CheckTarIsAvailable(qat *QatService) bool {
 	// 检查 tar 版本
-	cmd := exec.Command("tar", "--version")
+	cmd := qat.Exec.Command(qat.Exec.Tar(), "--version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("tar 未安装或无法执行: %v\n", err)
+		LoggerOf(qat.Logger).Error("tar 未安装或无法执行", "command", cmd.String(), "error", err)
 		qat.TarIsAvailable = false
 		return false
 	}
-	fmt.Printf("tar 版本信息:\n%s\n", output)
+	LoggerOf(qat.Logger).Info("tar 版本信息", "command", cmd.String(), "output", string(output))
 
 	qat.TarIsAvailable = true
 	return true
@@ -59,25 +55,26 @@ func CheckTarIsAvailable(qat *QatService) bool {
 
 func CheckQATEnv(qat *QatService) bool {
 	var available bool = true
-	// 获取环境变量
-	icpRoot := os.Getenv("ICP_ROOT")
-	qzRoot := os.Getenv("QZ_ROOT")
+	logger := LoggerOf(qat.Logger)
+	// 获取环境变量：优先使用执行配置，其次使用子进程环境
+	icpRoot := qat.Exec.GetIcpRoot()
+	qzRoot := qat.Exec.GetQzRoot()
 
 	// 检查环境变量是否存在且不为空
 	if icpRoot == "" {
-		log.Println("ICP_ROOT 环境变量不存在或为空")
+		logger.Warn("ICP_ROOT 环境变量不存在或为空")
 		available = false
 	} else {
-		log.Println(greenBold, "ICP_ROOT:", icpRoot, reset)
+		logger.Info("ICP_ROOT", "path", icpRoot)
 
 		qat.IcpRoot = &icpRoot
 	}
 
 	if qzRoot == "" {
-		log.Println("QZ_ROOT 环境变量不存在或为空")
+		logger.Warn("QZ_ROOT 环境变量不存在或为空")
 		available = false
 	} else {
-		log.Println(greenBold, "QZ_ROOT:", qzRoot, reset)
+		logger.Info("QZ_ROOT", "path", qzRoot)
 
 		qat.QzRoot = &qzRoot
 	}
@@ -87,12 +84,13 @@ func CheckQATEnv(qat *QatService) bool {
 func CheckQATHWState(qat *QatService) bool {
 	// 执行命令并获取输出
 	// 创建命令
-	cmd := exec.Command("service", "qat_service", "status")
+	cmd := qat.Exec.Command(qat.Exec.Service(), "qat_service", "status")
 
+	logger := LoggerOf(qat.Logger)
 	// 获取输出
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("执行命令时出错: %s\n", err)
+		logger.Error("执行命令时出错", "command", cmd.String(), "error", err)
 		return false
 	}
 
@@ -110,14 +108,14 @@ func CheckQATHWState(qat *QatService) bool {
 	matches := re.FindAllStringSubmatch(string(output), -1)
 
 	if len(matches) == 0 {
-		log.Println("\033[1;31m未找到任何设备的信息\033[0m")
+		logger.Error("未找到任何设备的信息", "command", cmd.String())
 		return false
 	}
 
 	// 遍历所有匹配项并输出
 	var hardwareses = make([]*Hardwarese, 0)
 	for _, match := range matches {
-		log.Printf("%s%s type: %s, state: %s%s\n", greenBold, match[1], match[2], match[3], reset)
+		logger.Info("QAT 设备", "device", match[1], "type", match[2], "state", match[3])
 
 		hardwareses = append(hardwareses, &Hardwarese{
 			Name:   &match[1],
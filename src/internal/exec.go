@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 子进程执行配置
+//
+// qzip/tar/service 默认通过 PATH 查找，子进程默认继承当前进程的环境变量。
+// 当 QATzip 安装在 $QZ_ROOT 下并依赖自定义的 LD_LIBRARY_PATH 时，
+// 可以在这里指定可执行文件的绝对路径以及传递给子进程的环境变量。
+type ExecConfig struct {
+	// qzip 可执行文件路径
+	QzipPath string
+	// tar 可执行文件路径
+	TarPath string
+	// service 可执行文件路径，用于查询 qat_service 状态
+	ServicePath string
+	// 子进程环境变量，格式为 KEY=VALUE；为 nil 时继承当前进程环境
+	Env []string
+	// ICP_ROOT，不为空时覆盖 Env 中的同名变量
+	IcpRoot string
+	// QZ_ROOT，不为空时覆盖 Env 中的同名变量
+	QzRoot string
+	// LD_LIBRARY_PATH，不为空时覆盖 Env 中的同名变量
+	LdLibraryPath string
+}
+
+// 获取默认的执行配置：通过 PATH 查找可执行文件，继承当前进程环境
+func GetDefaultExecConfig() ExecConfig {
+	return ExecConfig{
+		QzipPath:    "qzip",
+		TarPath:     "tar",
+		ServicePath: "service",
+		Env:         nil,
+	}
+}
+
+// 获取 qzip 可执行文件路径，未设置时使用 PATH 中的 qzip
+func (e ExecConfig) Qzip() string {
+	if e.QzipPath == "" {
+		return "qzip"
+	}
+	return e.QzipPath
+}
+
+// 获取 tar 可执行文件路径，未设置时使用 PATH 中的 tar
+func (e ExecConfig) Tar() string {
+	if e.TarPath == "" {
+		return "tar"
+	}
+	return e.TarPath
+}
+
+// 获取 service 可执行文件路径，未设置时使用 PATH 中的 service
+func (e ExecConfig) Service() string {
+	if e.ServicePath == "" {
+		return "service"
+	}
+	return e.ServicePath
+}
+
+// 获取 ICP_ROOT：优先使用配置，其次使用子进程环境中的值
+func (e ExecConfig) GetIcpRoot() string {
+	if e.IcpRoot != "" {
+		return e.IcpRoot
+	}
+	return lookupEnv(e.Environ(), "ICP_ROOT")
+}
+
+// 获取 QZ_ROOT：优先使用配置，其次使用子进程环境中的值
+func (e ExecConfig) GetQzRoot() string {
+	if e.QzRoot != "" {
+		return e.QzRoot
+	}
+	return lookupEnv(e.Environ(), "QZ_ROOT")
+}
+
+// 构建传递给子进程的环境变量
+func (e ExecConfig) Environ() []string {
+	var env []string
+	if e.Env == nil {
+		env = os.Environ()
+	} else {
+		env = make([]string, len(e.Env))
+		copy(env, e.Env)
+	}
+	env = setEnv(env, "ICP_ROOT", e.IcpRoot)
+	env = setEnv(env, "QZ_ROOT", e.QzRoot)
+	env = setEnv(env, "LD_LIBRARY_PATH", e.LdLibraryPath)
+	return env
+}
+
+// 使用配置中的环境变量创建子进程命令
+func (e ExecConfig) Command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = e.Environ()
+	return cmd
+}
+
+// 使用配置中的环境变量创建子进程命令，ctx 结束时终止子进程；ctx 为 nil 时与 Command 相同
+func (e ExecConfig) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if ctx == nil {
+		return e.Command(name, args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = e.Environ()
+	// 子进程被终止后，它的子进程可能仍持有输出管道，等待一段时间后不再读取
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// 设置环境变量（移除已有的同名变量），value 为空时保持原值
+func setEnv(env []string, key, value string) []string {
+	if value == "" {
+		return env
+	}
+	prefix := key + "="
+	result := env[:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, prefix) {
+			result = append(result, kv)
+		}
+	}
+	return append(result, prefix+value)
+}
+
+// 从环境变量列表中查找变量，存在多个时以最后一个为准（与 os/exec 一致）
+func lookupEnv(env []string, key string) string {
+	prefix := key + "="
+	value := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, prefix) {
+			value = kv[len(prefix):]
+		}
+	}
+	return value
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+)
+
+// 丢弃所有日志的 slog.Handler，未注入 Logger 时使用，保证默认静默
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// 静默的 Logger
+var discardLogger = slog.New(discardHandler{})
+
+// 获取可用的 Logger，为 nil 时返回静默的 Logger
+func LoggerOf(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
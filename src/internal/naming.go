@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 按命名模板生成压缩输出文件的路径
+//
+// name 为不带压缩后缀的输出路径（源文件或 -o 指定的名称），ext 为压缩后缀，模板支持以下占位符：
+//
+//	{dir}   name 所在目录
+//	{name}  name 的文件名，如 test.json
+//	{stem}  去除最后一个扩展名的文件名，如 test
+//	{fext}  文件名的最后一个扩展名，如 .json
+//	{ext}   压缩后缀，如 .gz、.lz4
+//	{date}  当前日期，如 20060102
+//	{time}  当前时间，如 150405
+//
+// 例如 {dir}/{stem}.{date}{ext} 将 /data/test.json 命名为 /data/test.20060102.gz
+func RenderNameTemplate(template, name, ext string, now time.Time) (string, error) {
+	base := filepath.Base(name)
+	fext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, fext)
+	if stem == "" {
+		// 以点开头且没有其他扩展名的文件，如 .bashrc
+		stem, fext = base, ""
+	}
+	values := map[string]string{
+		"dir":  filepath.Dir(name),
+		"name": base,
+		"stem": stem,
+		"fext": fext,
+		"ext":  ext,
+		"date": now.Format("20060102"),
+		"time": now.Format("150405"),
+	}
+	var b strings.Builder
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid name template %q: unclosed placeholder", template)
+		}
+		key := rest[start+1 : start+end]
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("invalid name template %q: unknown placeholder {%s}", template, key)
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+	return filepath.Clean(b.String()), nil
+}
@@ -32,6 +32,20 @@ const (
 	GZIPEXT
 )
 
+// 算法名称，与 -A 选项的参数一致；未指定时 qzip 默认使用 gzipext
+func (a ALGORITHM_TYPE) String() string {
+	switch a {
+	case LZ4:
+		return "lz4"
+	case LZ4S:
+		return "lz4s"
+	case GZIP:
+		return "gzip"
+	default:
+		return "gzipext"
+	}
+}
+
 // qzip -O 选项支持的文件头
 const (
 	_ FILE_HEADER = iota
@@ -173,11 +187,34 @@ func (t *TarCommand) SetArchiveFile() {
 // eg：tar -xvf mydir.tgz -I "qzip -d" -C ./
 //
 // 原因：exec.Cmd类型的Options属性是[]string类型的，因此每个选项都可以正确传递给shell
+//
+// qzip 路径来自 Exec 配置，tar 会按空白分割 -I 的参数，因此路径中不能包含空格
 func (t *TarCommand) SetQzipCommand() {
 	if t.Compression {
-		t.Options = append(t.Options, "-I", "qzip")
+		t.Options = append(t.Options, "-I", t.Exec.Qzip())
 	} else {
-		t.Options = append(t.Options, "-I", "qzip -d")
+		t.Options = append(t.Options, "-I", t.Exec.Qzip()+" -d")
+	}
+}
+
+// 可复现归档的 tar 选项：固定归档格式，条目按名称排序，修改时间统一为 1970-01-01，
+// 属主与属组统一为 0 且不记录用户名，权限统一为 644/755（保留可执行位，去掉组与其他用户的写权限以及 setuid/setgid/sticky）
+var reproducibleTarOptions = []string{
+	"--format=gnu",
+	"--sort=name",
+	"--mtime=@0",
+	"--owner=0",
+	"--group=0",
+	"--numeric-owner",
+	"--mode=u+rw,go-w,a+rX,a-st",
+}
+
+// 设置可复现归档的选项，只在压缩时生效
+//
+// 相同的目录树无论文件系统顺序、修改时间与属主如何，都生成相同的 tar 数据流
+func (t *TarCommand) SetReproducible() {
+	if t.Reproducible && t.Compression {
+		t.Options = append(t.Options, reproducibleTarOptions...)
 	}
 }
 
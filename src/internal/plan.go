@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 命令执行计划
+//
+// dry-run 模式下返回，描述将要执行的命令、预期生成的文件以及会被删除的源文件，不会执行任何命令
+type Plan struct {
+	// 完整的命令行参数，Argv[0] 为可执行文件
+	Argv []string
+	// 命令的工作目录，为空表示当前工作目录
+	Dir string
+	// 不在命令行参数中、由 Go 读取后写入命令标准输入的源文件或目录
+	Inputs []string
+	// 预期生成的文件或目录
+	Outputs []string
+	// 执行成功后会被删除的源文件
+	Deletes []string
+}
+
+// qzip 处理的单个文件：输入文件以及对应的输出文件
+type Target struct {
+	// 输入文件
+	Input string
+	// 输出文件，无法确定时为空
+	Output string
+}
+
+// 各格式的压缩后缀，与 qzip 追加的后缀一致
+const (
+	SuffixGzip = ".gz"
+	SuffixLz4  = ".lz4"
+	SuffixLz4s = ".lz4s"
+)
+
+// 解压时可以识别并去除的后缀
+var decompressSuffixes = []string{SuffixGzip, SuffixLz4s, SuffixLz4}
+
+// 解压的文件没有可识别的压缩后缀
+var ErrUnknownSuffix = errors.New("unknown compressed suffix")
+
+// 生成qzip命令的执行计划，与 ExecuteQzipCommand 使用相同的参数检查
+func PlanQzipCommand(cmd QzipCommand) (Plan, error) {
+	// 复制选项，避免构建命令时修改调用方的切片
+	cmd.Options = append([]string(nil), cmd.Options...)
+	qzipCmd, err := prepareQzipCommand(&cmd)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{
+		Argv: append([]string(nil), qzipCmd.Args...),
+		Dir:  qzipCmd.Dir,
+	}
+	targets, err := QzipTargets(cmd)
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, target := range targets {
+		if target.Output != "" {
+			plan.Outputs = append(plan.Outputs, target.Output)
+		}
+		if !cmd.KeepSource {
+			plan.Deletes = append(plan.Deletes, target.Input)
+		}
+	}
+	return plan, nil
+}
+
+// 获取qzip命令会处理的每一个文件及其输出文件，目录会展开为目录下的每一个文件
+func QzipTargets(cmd QzipCommand) ([]Target, error) {
+	sources, err := cmd.sourceFiles()
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]Target, 0, len(sources))
+	for _, src := range sources {
+		output, err := cmd.outputOf(src, len(sources))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{Input: src, Output: output})
+	}
+	return targets, nil
+}
+
+// 生成tar命令的执行计划，与 ExecuteTarCommand 使用相同的参数检查
+func PlanTarCommand(cmd TarCommand) (Plan, error) {
+	cmd.Options = append([]string(nil), cmd.Options...)
+	tarCmd, err := prepareTarCommand(&cmd)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{
+		Argv: append([]string(nil), tarCmd.Args...),
+		Dir:  tarCmd.Dir,
+	}
+	if cmd.Compression {
+		// 压缩：生成归档文件
+		plan.Outputs = []string{cmd.ArchiveFile}
+	} else if cmd.OutputFile != "" {
+		// 解压：归档内容写入 -C 指定的目录
+		plan.Outputs = []string{cmd.OutputFile}
+	} else {
+		// 解压：未指定输出目录时写入归档文件所在目录
+		plan.Outputs = []string{tarCmd.Dir}
+	}
+	return plan, nil
+}
+
+// qzip 实际会处理的源文件：目录展开为其中的文件
+func (q *QzipCommand) sourceFiles() ([]string, error) {
+	var files []string
+	for _, input := range q.InputFile {
+		info, err := os.Stat(input)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("input file %s does not exist", input)
+		} else if err != nil {
+			return nil, err
+		}
+		if !q.IsDirctory || !info.IsDir() {
+			files = append(files, input)
+			continue
+		}
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && q.handles(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// 目录模式下qzip是否会处理该文件：解压只处理带有压缩后缀的文件
+func (q *QzipCommand) handles(path string) bool {
+	if q.Compression {
+		return true
+	}
+	return TrimCompressedSuffix(path) != path
+}
+
+// 源文件对应的输出文件
+//
+// 压缩：在 -o 指定的名称（未指定时为源文件）后追加格式对应的后缀，设置了 NameTemplate 时按模板生成；
+// 解压：-o 指定的名称，未指定时去除源文件的压缩后缀，没有可识别的后缀时返回 ErrUnknownSuffix
+func (q *QzipCommand) outputOf(src string, count int) (string, error) {
+	name := src
+	// -o 仅在单个文件时有效
+	if q.OutputFile != "" && !q.IsDirctory && count == 1 {
+		if !q.Compression {
+			return q.OutputFile, nil
+		}
+		name = q.OutputFile
+	}
+	if q.Compression {
+		if q.NameTemplate != "" {
+			output, err := RenderNameTemplate(q.NameTemplate, name, q.Extension(), time.Now())
+			if err == nil && output == filepath.Clean(src) {
+				err = fmt.Errorf("invalid name template %q: output is the input file %s", q.NameTemplate, src)
+			}
+			return output, err
+		}
+		return name + q.Extension(), nil
+	}
+	return StripCompressedSuffix(src)
+}
+
+// 压缩输出的后缀：由文件头格式（-O）决定，未指定时由算法（-A）决定
+func (q *QzipCommand) Extension() string {
+	switch q.FileHeader {
+	case FILE_HEADER_LZ4:
+		return SuffixLz4
+	case FILE_HEADER_LZ4S:
+		return SuffixLz4s
+	case FILE_HEADER_GZIP, FILE_HEADER_GZIPEXT:
+		return SuffixGzip
+	}
+	switch q.Algorithm {
+	case LZ4:
+		return SuffixLz4
+	case LZ4S:
+		return SuffixLz4s
+	default:
+		return SuffixGzip
+	}
+}
+
+// 去除压缩后缀，没有可识别的后缀时原样返回
+func TrimCompressedSuffix(path string) string {
+	for _, suffix := range decompressSuffixes {
+		if strings.HasSuffix(path, suffix) && len(path) > len(suffix) && !strings.HasSuffix(path, string(filepath.Separator)+suffix) {
+			return strings.TrimSuffix(path, suffix)
+		}
+	}
+	return path
+}
+
+// 去除压缩后缀，没有可识别的后缀时返回 ErrUnknownSuffix
+func StripCompressedSuffix(path string) (string, error) {
+	if out := TrimCompressedSuffix(path); out != path {
+		return out, nil
+	}
+	return "", fmt.Errorf("%w: %s (expected %s)", ErrUnknownSuffix, path, strings.Join(decompressSuffixes, ", "))
+}
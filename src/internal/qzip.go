@@ -1,11 +1,14 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 )
 
 type (
@@ -15,6 +18,8 @@ type (
 		InputFile []string
 		// -o 输出文件
 		OutputFile string
+		// 压缩输出文件的命名模板，如 {dir}/{stem}.{date}{ext}，见 RenderNameTemplate；为空时在源文件名后追加后缀
+		NameTemplate string
 		// -O 压缩文件头格式
 		FileHeader FILE_HEADER
 		// 是否压缩 否：-d 解压缩
@@ -33,6 +38,12 @@ type (
 		Concurrency int
 		// 其他单独选项
 		Options []string // 用于存储其他选项
+		// 子进程执行配置
+		Exec ExecConfig
+		// 日志输出，为 nil 时不输出任何日志
+		Logger *slog.Logger
+		// 结束时终止正在执行的 qzip，为 nil 时不会被取消
+		Context context.Context
 	}
 
 	TarCommand struct {
@@ -46,8 +57,16 @@ type (
 		Compression bool
 		// 目录层级
 		components int
+		// 压缩时生成可复现的 tar 数据流，见 SetReproducible
+		Reproducible bool
 		// 其他单独选项
 		Options []string // 用于存储其他选项
+		// 子进程执行配置，-I 选项中的 qzip 同样使用此配置
+		Exec ExecConfig
+		// 日志输出，为 nil 时不输出任何日志
+		Logger *slog.Logger
+		// 结束时终止正在执行的 tar，为 nil 时不会被取消
+		Context context.Context
 	}
 
 	COMPRESSION_LEVEL int
@@ -55,6 +74,12 @@ type (
 	FILE_HEADER       int
 )
 
+// ErrEntryCollision is returned when two inputs of a tar archive map to the same entry.
+var ErrEntryCollision = errors.New("archive entries collide")
+
+// 归档文件为 StreamArchive 时，tar 将归档写入标准输出或从标准输入读取
+const StreamArchive = "-"
+
 // 获取默认的qzip命令
 func GetDefaultQzipCommand() QzipCommand {
 	return QzipCommand{
@@ -69,6 +94,7 @@ func GetDefaultQzipCommand() QzipCommand {
 		OutputFile:  "",
 		InputFile:   nil,
 		Concurrency: 10,
+		Exec:        GetDefaultExecConfig(),
 	}
 }
 
@@ -81,6 +107,7 @@ func GetDefaultTarCommand() TarCommand {
 		ArchiveFile: "",
 		components:  0,
 		Options:     nil,
+		Exec:        GetDefaultExecConfig(),
 	}
 }
 
@@ -107,31 +134,41 @@ func (q *QzipCommand) BuildQzipCommand() *exec.Cmd {
 	// 设置并发数
 	q.SetConcurrency()
 	q.Options = append(q.Options, q.InputFile...)
-	return exec.Command("qzip", q.Options...)
+	return q.Exec.CommandContext(q.Context, q.Exec.Qzip(), q.Options...)
 }
 
-func ExecuteQzipCommand(cmd QzipCommand) error {
+// 检查输入文件并构建qzip命令
+func prepareQzipCommand(cmd *QzipCommand) (*exec.Cmd, error) {
 	// 判断传入的文件是否存在
 	if condition := len(cmd.InputFile) != 0; condition {
 		for _, file := range cmd.InputFile {
 			if _, err := os.Stat(file); os.IsNotExist(err) {
-				return fmt.Errorf("input file %s does not exist", file)
+				return nil, fmt.Errorf("input file %s does not exist", file)
 			}
 		}
 	}
-	// 执行qzip命令
 	qzipCmd := cmd.BuildQzipCommand()
 	if condition := qzipCmd == nil; condition {
-		return errors.New("qzip command or inputFile is nil")
+		return nil, errors.New("qzip command or inputFile is nil")
 	}
-	fmt.Println("Executing command:", qzipCmd.String())
-	output, err := qzipCmd.CombinedOutput()
+	return qzipCmd, nil
+}
+
+func ExecuteQzipCommand(cmd QzipCommand) error {
+	_, err := RunQzipCommand(cmd)
+	return err
+}
+
+// 执行qzip命令并返回qzip的输出（包含qzip统计的耗时与吞吐量）
+func RunQzipCommand(cmd QzipCommand) (string, error) {
+	// 执行qzip命令
+	qzipCmd, err := prepareQzipCommand(&cmd)
 	if err != nil {
-		return fmt.Errorf("error executing command: %s, output: %s", err, output)
+		return "", err
 	}
-	fmt.Printf("Output: %s\n", output)
-	return nil
+	return runCommand(qzipCmd, cmd.Logger, cmd.InputFile)
 }
+
 func (t *TarCommand) BuildTarCommand() *exec.Cmd {
 	// 压缩必须有输入文件
 	if len(t.InputFile) == 0 && t.Compression {
@@ -142,85 +179,143 @@ func (t *TarCommand) BuildTarCommand() *exec.Cmd {
 	// 设置归档文件，必须在-f选项之后
 	t.SetArchiveFile()
 	t.SetQzipCommand()
+	t.SetReproducible()
 	t.SetOutputFile()
 	t.SetInputFile()
 	t.SetComponents()
-	return exec.Command("tar", t.Options...)
+	return t.Exec.CommandContext(t.Context, t.Exec.Tar(), t.Options...)
 }
 
-func ExecuteTarCommand(cmd TarCommand) error {
+// 检查输入文件并构建tar命令，命令的工作目录为数据的父目录
+func prepareTarCommand(cmd *TarCommand) (*exec.Cmd, error) {
 	// 判断传入的文件是否存在
 	// 压缩：输入文件
 	// 解压缩：归档文件（输入文件）
 	if cmd.Compression {
 		if len(cmd.InputFile) != 0 {
 			for _, f := range cmd.InputFile {
-				if !fileIsExist(f) {
-					return fmt.Errorf("file %s does not exist", f)
+				if !FileIsExist(f) {
+					return nil, fmt.Errorf("file %s does not exist", f)
 				}
 			}
 		} else {
-			return errors.New("input file is empty")
+			return nil, errors.New("input file is empty")
 		}
-	} else {
-		if !fileIsExist(cmd.ArchiveFile) {
-			return fmt.Errorf("file %s does not exist", cmd.ArchiveFile)
+	} else if cmd.ArchiveFile != StreamArchive {
+		if !FileIsExist(cmd.ArchiveFile) {
+			return nil, fmt.Errorf("file %s does not exist", cmd.ArchiveFile)
 		}
 	}
 	// ***********************************************************
 	// 这段操作是为了保证压缩的目录层级只有一层，如：/tmp/tt/test.txt 解压会得到/path-you-want/tt/test.txt，而不是/path-you-want/tmp/tt/test.txt
-	// 因为文件或者目录的层级不定，所以需要进入父目录使用相对路径进行一级层级压缩
-	// 记录当前工作目录
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return errors.New("error getting current directory")
+	// 因为文件或者目录的层级不定，所以需要在父目录中使用相对路径进行一级层级压缩
+	// 命令的工作目录会切换到数据的父目录，因此归档文件和输出目录需要先转换为绝对路径
+	var err error
+	if cmd.ArchiveFile != "" && cmd.ArchiveFile != StreamArchive {
+		if cmd.ArchiveFile, err = filepath.Abs(cmd.ArchiveFile); err != nil {
+			return nil, fmt.Errorf("error resolving archive file: %s", err)
+		}
+	}
+	if cmd.OutputFile != "" {
+		if cmd.OutputFile, err = filepath.Abs(cmd.OutputFile); err != nil {
+			return nil, fmt.Errorf("error resolving output directory: %s", err)
+		}
 	}
 	//  提取目录层级：/tmp/test.txt --> /tmp 或/tmp/tt/test.txt --> /tmp/tt
 	dataFatherPath := ""
 	if cmd.InputFile != nil {
 		// 压缩从输入文件中提取目录
 		dataFatherPath = filepath.Dir(cmd.InputFile[0])
-		// 将输入文件去除父目录，使用相对路径/tmp/tt/test.txt --> test.txt
-		modifiedPaths := make([]string, len(cmd.InputFile))
-		for i, path := range cmd.InputFile {
-			modifiedPaths[i] = filepath.Base(path)
+		if cmd.InputFile, err = relativeTarInputs(cmd.InputFile, dataFatherPath); err != nil {
+			return nil, err
 		}
-		// 如果需要，可以将 modifiedPaths 赋值回 cmd.InputFile
-		cmd.InputFile = modifiedPaths
-		fmt.Println("Data father path:", dataFatherPath)
-	} else if cmd.ArchiveFile != "" && !cmd.Compression {
+	} else if cmd.ArchiveFile != "" && cmd.ArchiveFile != StreamArchive && !cmd.Compression {
 		// 解压从归档文件中提取，因为归档文件是解压操作的输入
 		dataFatherPath = filepath.Dir(cmd.ArchiveFile)
-		fmt.Println("Data father path:", dataFatherPath)
-	}
-	// 切换到 数据的父目录
-	err = os.Chdir(dataFatherPath)
-	if err != nil {
-		return errors.New("error changing directory")
 	}
-	// 最终切换到原本的工作目录
-	defer func() {
-		err := os.Chdir(originalDir)
-		if err != nil {
-			fmt.Println("Error returning to original directory:", err)
-		}
-	}()
 	// ***********************************************************
 	tarCmd := cmd.BuildTarCommand()
 	if condition := tarCmd == nil; condition {
-		return errors.New("tar command or inputFile is nil")
+		return nil, errors.New("tar command or inputFile is nil")
 	}
-	fmt.Println("Executing command:", tarCmd.String())
-	output, err := tarCmd.CombinedOutput()
+	// 在数据的父目录中执行，不改变当前进程的工作目录
+	tarCmd.Dir = dataFatherPath
+	return tarCmd, nil
+}
+
+// 将输入文件去除父目录，使用相对路径：/tmp/tt/test.txt --> test.txt
+//
+// 输入文件位于不同的父目录时，在每个输入文件之前使用 -C 切换到其父目录（绝对路径）；
+// 文件名相同的输入在归档中是同一个条目，返回 ErrEntryCollision
+func relativeTarInputs(inputs []string, dataFatherPath string) ([]string, error) {
+	sameParent := true
+	for _, path := range inputs {
+		sameParent = sameParent && filepath.Dir(path) == dataFatherPath
+	}
+	names := make(map[string]string, len(inputs))
+	var args []string
+	for _, path := range inputs {
+		base := filepath.Base(path)
+		if prev, ok := names[base]; ok {
+			return nil, fmt.Errorf("%w: %s and %s are both archived as %s", ErrEntryCollision, prev, path, base)
+		}
+		names[base] = path
+		if !sameParent {
+			parent, err := filepath.Abs(filepath.Dir(path))
+			if err != nil {
+				return nil, fmt.Errorf("error resolving input file: %s", err)
+			}
+			args = append(args, "-C", parent)
+		}
+		args = append(args, base)
+	}
+	return args, nil
+}
+
+// 检查输入并构建 tar 子进程，由调用方设置标准输入输出并执行；
+// ArchiveFile 为 StreamArchive 时归档写入标准输出（压缩）或从标准输入读取（解压）
+func TarProcess(cmd TarCommand) (*exec.Cmd, error) {
+	cmd.Options = append([]string(nil), cmd.Options...)
+	return prepareTarCommand(&cmd)
+}
+
+func ExecuteTarCommand(cmd TarCommand) error {
+	_, err := RunTarCommand(cmd)
+	return err
+}
+
+// 执行tar命令并返回tar的输出（-v 列出的归档条目）
+func RunTarCommand(cmd TarCommand) (string, error) {
+	// prepareTarCommand 会将输入改写为相对路径与 -C 参数，日志记录原始的输入
+	files := append([]string(nil), cmd.InputFile...)
+	if !cmd.Compression {
+		files = []string{cmd.ArchiveFile}
+	}
+	tarCmd, err := prepareTarCommand(&cmd)
+	if err != nil {
+		return "", err
+	}
+	return runCommand(tarCmd, cmd.Logger, files)
+}
+
+// 执行命令并记录命令、文件、耗时等日志，返回命令的输出
+func runCommand(c *exec.Cmd, logger *slog.Logger, files []string) (string, error) {
+	logger = LoggerOf(logger)
+	logger.Info("executing command", "command", c.String(), "dir", c.Dir, "file", files)
+	start := time.Now()
+	output, err := c.CombinedOutput()
+	duration := time.Since(start)
 	if err != nil {
-		return fmt.Errorf("error executing command: %s, output: %s", err, output)
+		logger.Error("command failed", "command", c.String(), "file", files, "duration", duration, "error", err, "output", string(output))
+		return string(output), fmt.Errorf("error executing command: %s, output: %s", err, output)
 	}
-	fmt.Printf("Output: %s\n", output)
-	return nil
+	logger.Info("command finished", "command", c.String(), "file", files, "duration", duration)
+	logger.Debug("command output", "command", c.String(), "output", string(output))
+	return string(output), nil
 }
 
 // 检测文件或者目录是否存在
-func fileIsExist(path string) bool {
+func FileIsExist(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil || os.IsExist(err)
 }
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// qzip 每处理完一个文件输出的统计信息
+//
+//	Time taken:     1234.567 ms
+//	Throughput:     1234.567 Mbit/s
+type QzipStats struct {
+	// qzip 统计的耗时
+	Time time.Duration
+	// qzip 统计的吞吐量，单位 Mbit/s
+	Throughput float64
+}
+
+var (
+	timeTakenRe  = regexp.MustCompile(`Time taken:\s*([0-9.]+)\s*ms`)
+	throughputRe = regexp.MustCompile(`Throughput:\s*([0-9.]+)\s*Mbit/s`)
+)
+
+// 解析qzip输出中的统计信息，按文件处理顺序返回
+func ParseQzipStats(output string) []QzipStats {
+	times := timeTakenRe.FindAllStringSubmatch(output, -1)
+	throughputs := throughputRe.FindAllStringSubmatch(output, -1)
+	stats := make([]QzipStats, len(times))
+	for i, match := range times {
+		if ms, err := strconv.ParseFloat(match[1], 64); err == nil {
+			stats[i].Time = time.Duration(ms * float64(time.Millisecond))
+		}
+		if i < len(throughputs) {
+			if mbps, err := strconv.ParseFloat(throughputs[i][1], 64); err == nil {
+				stats[i].Throughput = mbps
+			}
+		}
+	}
+	return stats
+}
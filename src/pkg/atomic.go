@@ -0,0 +1,427 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// 临时目录前缀，临时目录创建在输出文件旁边，保证与最终路径位于同一文件系统，可以直接重命名；
+// 临时目录的名称在执行时生成，DryRun 的计划中以该模式表示
+const atomicTempPattern = ".qzipgo-*"
+
+// 以原子方式执行qzip命令：每个文件单独调用一次 qzip，输出写入临时目录，成功后 fsync 并重命名到最终路径
+//
+// 某个文件失败后不再启动新的文件，已经完成的文件保留在 report 中；DryRun 时为每个文件记录一个执行计划
+func (c *Client) runQzipAtomic(report *Report, cmd internal.QzipCommand) error {
+	targets, err := internal.QzipTargets(cmd)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if target.Output == "" {
+			return fmt.Errorf("cannot determine the output file of %s", target.Input)
+		}
+	}
+	if c.DryRun {
+		for _, target := range targets {
+			if err := c.planQzipTarget(report, cmd, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	parallelism := c.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	results := make([]*Result, len(targets))
+	errs := make([]error, len(targets))
+	var failed atomic.Bool
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range targets {
+		sem <- struct{}{}
+		if failed.Load() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = c.runQzipTarget(cmd, targets[i])
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var joined []error
+	for i, result := range results {
+		if result != nil {
+			report.Results = append(report.Results, *result)
+			report.addCollision(targets[i].Output, result.Output, result.Decision)
+			if c.SafeDelete != nil && !cmd.KeepSource && result.Decision != DecisionSkipped && !result.Verified {
+				report.Unverified = append(report.Unverified, result.Input)
+			}
+		}
+		if errs[i] != nil {
+			joined = append(joined, fmt.Errorf("%s: %w", targets[i].Input, errs[i]))
+		}
+	}
+	return errors.Join(joined...)
+}
+
+// 处理单个文件：qzip -k -o tmpDir/out input，成功后将输出重命名为 target.Output（或按 Collision 策略决定的路径），
+// 不保留源文件时再删除源文件
+func (c *Client) runQzipTarget(cmd internal.QzipCommand, target internal.Target) (*Result, error) {
+	if err := c.context().Err(); err != nil {
+		return nil, err
+	}
+	output, decision, err := c.resolveOutput(target.Output)
+	if err != nil {
+		return nil, err
+	}
+	if decision == DecisionSkipped {
+		// 保留已存在的输出与源文件
+		return &Result{Input: target.Input, Output: target.Output, Algorithm: cmd.Algorithm.String(), Decision: decision}, nil
+	}
+	dir := filepath.Dir(target.Output)
+	tmpDir, err := os.MkdirTemp(dir, atomicTempPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	single, err := singleQzipCommand(cmd, target.Input, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	size := sizeOf(target.Input)
+	start := time.Now()
+	var stdout string
+	if !single.Compression && c.softwareDecompresses(target.Input) {
+		err = decompressSoftware(target.Input, single.OutputFile)
+	} else {
+		stdout, err = internal.RunQzipCommand(single)
+	}
+	if err != nil {
+		return nil, err
+	}
+	produced, err := onlyFile(tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	// 安全删除：在输出移动到最终路径之前校验，校验失败时不会留下输出
+	safeDelete := !cmd.KeepSource && c.SafeDelete != nil
+	verified := false
+	if safeDelete {
+		if verified, err = verifyPair(cmd.Compression, target.Input, produced); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.commitFile(produced, output, decision); err != nil {
+		return nil, err
+	}
+	target.Output = output
+	result := qzipResults(single, []internal.Target{target}, []int64{size}, stdout, time.Since(start))[0]
+	result.Decision = decision
+	if safeDelete {
+		err = c.removeVerified(&result, verified)
+	} else if !cmd.KeepSource {
+		err = os.Remove(target.Input)
+	}
+	// 输出已经写入最终路径，删除源文件失败时仍返回结果
+	return &result, err
+}
+
+// 处理单个文件的 qzip 命令：qzip -k -o tmpDir/out input
+func singleQzipCommand(cmd internal.QzipCommand, input, tmpDir string) (internal.QzipCommand, error) {
+	single := cmd
+	single.Options = append([]string(nil), cmd.Options...)
+	single.IsDirctory = false
+	single.Recursive = false
+	// 源文件在输出重命名之后再删除
+	single.KeepSource = true
+	single.InputFile = []string{input}
+	// 压缩时 qzip 会在 -o 的名称后追加后缀，实际生成的文件从临时目录中查找
+	single.OutputFile = filepath.Join(tmpDir, "out")
+	single, _, err := detectDecompression(single)
+	return single, err
+}
+
+// 记录 runQzipTarget 的执行计划：与执行时相同地处理已存在的输出，跳过的文件没有计划；
+// Software 模式下在 Go 中解压的文件没有命令行参数
+func (c *Client) planQzipTarget(report *Report, cmd internal.QzipCommand, target internal.Target) error {
+	output, decision, err := c.resolveOutput(target.Output)
+	if err != nil {
+		return err
+	}
+	report.addCollision(target.Output, output, decision)
+	if decision == DecisionSkipped {
+		return nil
+	}
+	plan := Plan{Outputs: []string{output}}
+	if !cmd.KeepSource {
+		plan.Deletes = []string{target.Input}
+	}
+	if cmd.Compression || !c.softwareDecompresses(target.Input) {
+		single, err := singleQzipCommand(cmd, target.Input, filepath.Join(filepath.Dir(target.Output), atomicTempPattern))
+		if err != nil {
+			return err
+		}
+		qzipPlan, err := internal.PlanQzipCommand(single)
+		if err != nil {
+			return err
+		}
+		plan.Argv, plan.Dir = qzipPlan.Argv, qzipPlan.Dir
+	}
+	report.Plans = append(report.Plans, plan)
+	return nil
+}
+
+// 以原子方式执行tar命令
+//
+// 压缩：归档写入临时目录，成功后 fsync 并重命名为归档文件；
+// 解压：解压到输出目录中的临时目录，成功后 fsync 全部文件并移动到输出目录
+func (c *Client) runTarAtomic(report *Report, cmd internal.TarCommand) error {
+	var dir string
+	if cmd.Compression {
+		dir = filepath.Dir(cmd.ArchiveFile)
+	} else if cmd.OutputFile != "" {
+		dir = cmd.OutputFile
+	} else {
+		dir = filepath.Dir(cmd.ArchiveFile)
+	}
+	decision := DecisionCreated
+	if cmd.Compression {
+		archive, d, err := c.resolveOutput(cmd.ArchiveFile)
+		if err != nil {
+			return err
+		}
+		report.addCollision(cmd.ArchiveFile, archive, d)
+		if d == DecisionSkipped {
+			if !c.DryRun {
+				report.Results = append(report.Results, Result{Input: strings.Join(cmd.InputFile, " "), Output: archive, Decision: d})
+			}
+			return nil
+		}
+		cmd.ArchiveFile, decision = archive, d
+	}
+	if c.DryRun {
+		plan, err := internal.PlanTarCommand(atomicTarCommand(cmd, filepath.Join(dir, atomicTempPattern)))
+		if err != nil {
+			return err
+		}
+		plan.Outputs = []string{dir}
+		if cmd.Compression {
+			plan.Outputs = []string{cmd.ArchiveFile}
+		}
+		report.Plans = append(report.Plans, plan)
+		return nil
+	}
+	tmpDir, err := os.MkdirTemp(dir, atomicTempPattern)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := atomicTarCommand(cmd, tmpDir)
+	start := time.Now()
+	output, err := internal.RunTarCommand(tmp)
+	if err != nil {
+		return err
+	}
+	if cmd.Compression {
+		err = c.commitFile(tmp.ArchiveFile, cmd.ArchiveFile, decision)
+	} else {
+		err = c.commitTree(report, tmpDir, dir)
+	}
+	if err != nil {
+		return err
+	}
+	result := tarResult(cmd, output, time.Since(start))
+	result.Decision = decision
+	report.Results = append(report.Results, result)
+	return nil
+}
+
+// 写入临时目录 tmpDir 的 tar 命令：压缩时归档写入 tmpDir，解压时解压到 tmpDir
+func atomicTarCommand(cmd internal.TarCommand, tmpDir string) internal.TarCommand {
+	tmp := cmd
+	tmp.Options = append([]string(nil), cmd.Options...)
+	if cmd.Compression {
+		tmp.ArchiveFile = filepath.Join(tmpDir, filepath.Base(cmd.ArchiveFile))
+	} else {
+		tmp.OutputFile = tmpDir
+	}
+	return tmp
+}
+
+// 获取目录中唯一的文件
+func onlyFile(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) != 1 || !entries[0].Type().IsRegular() {
+		return "", fmt.Errorf("unexpected qzip output in %s", dir)
+	}
+	return filepath.Join(dir, entries[0].Name()), nil
+}
+
+// 生成单个输出文件：按 Collision 策略确定输出路径，write 的数据先写入输出旁边的临时文件，成功后 fsync 并重命名；
+// 返回最终路径与处理结果，跳过时不调用 write
+func (c *Client) writeOutput(report *Report, output string, write func(w io.Writer) error) (string, Decision, error) {
+	final, decision, err := c.resolveOutput(output)
+	if err != nil {
+		return "", decision, err
+	}
+	report.addCollision(output, final, decision)
+	if decision == DecisionSkipped {
+		return final, decision, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(final), atomicTempPattern)
+	if err != nil {
+		return "", decision, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := write(tmp); err != nil {
+		return "", decision, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", decision, err
+	}
+	return final, decision, c.commitFile(tmp.Name(), final, decision)
+}
+
+// fsync 临时文件后重命名为最终路径，并 fsync 所在目录使重命名持久化
+//
+// 只有 decision 为覆盖时才会替换已存在的文件；否则以硬链接创建最终路径，
+// 检查之后才出现的同名文件使链接失败并返回 ErrOutputExists，不会被替换
+func (c *Client) commitFile(tmp, dst string, decision Decision) error {
+	if err := syncPath(tmp); err != nil {
+		return err
+	}
+	if decision == DecisionOverwritten {
+		if err := os.Rename(tmp, dst); err != nil {
+			return err
+		}
+	} else if err := linkNoReplace(tmp, dst); err != nil {
+		return err
+	}
+	return syncPath(filepath.Dir(dst))
+}
+
+// 将 tmp 移动到不存在的 dst：link 在 dst 已存在时以 EEXIST 原子地失败，成功后删除 tmp；
+// 文件系统不支持硬链接时退回到检查后重命名
+func linkNoReplace(tmp, dst string) error {
+	err := os.Link(tmp, dst)
+	switch {
+	case err == nil:
+		// 删除失败时临时文件由调用方清理
+		os.Remove(tmp)
+		return nil
+	case errors.Is(err, fs.ErrExist):
+		return fmt.Errorf("%w: %s", ErrOutputExists, dst)
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.ENOTSUP), errors.Is(err, syscall.EOPNOTSUPP):
+		if internal.FileIsExist(dst) {
+			return fmt.Errorf("%w: %s", ErrOutputExists, dst)
+		}
+		return os.Rename(tmp, dst)
+	default:
+		return err
+	}
+}
+
+// fsync src 下的全部文件后将其中的条目移动到 dst：已存在的目录合并，已存在的其他条目按 Collision 策略处理
+//
+// 策略为 CollisionFail 时先检查全部条目，存在冲突时不移动任何文件
+func (c *Client) commitTree(report *Report, src, dst string) error {
+	var conflicts []string
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Lstat(filepath.Join(dst, rel)); err == nil && rel != "." && !(d.IsDir() && info.IsDir()) {
+			conflicts = append(conflicts, filepath.Join(dst, rel))
+		}
+		if d.Type().IsRegular() || d.IsDir() {
+			return syncPath(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 && c.Collision == CollisionFail {
+		return fmt.Errorf("%w: %s", ErrOutputExists, strings.Join(conflicts, ", "))
+	}
+	return c.moveInto(report, src, dst)
+}
+
+// 将 src 目录中的条目移动到 dst 目录
+func (c *Client) moveInto(report *Report, src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		info, err := os.Lstat(to)
+		if err == nil && entry.IsDir() && info.IsDir() {
+			if err := c.moveInto(report, from, to); err != nil {
+				return err
+			}
+			continue
+		}
+		output, decision, err := c.resolveOutput(to)
+		if err != nil {
+			return err
+		}
+		report.addCollision(to, output, decision)
+		switch decision {
+		case DecisionSkipped:
+			continue
+		case DecisionOverwritten:
+			// 目录不能直接替换非空目录或文件，先删除已存在的条目
+			if entry.IsDir() || (info != nil && info.IsDir()) {
+				if err := os.RemoveAll(to); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Rename(from, output); err != nil {
+			return err
+		}
+	}
+	return syncPath(dst)
+}
+
+// fsync 文件或目录
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
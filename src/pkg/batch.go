@@ -0,0 +1,131 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// BatchOptions controls how CompressBatch and DecompressBatch handle per-file failures.
+//
+// 批量操作选项
+type BatchOptions struct {
+	// 为 true 时某个文件失败后继续处理剩余文件；为 false 时在第一个失败的文件处停止（fail-fast），
+	// 未处理的文件记录在 Report.Remaining 中
+	ContinueOnError bool
+}
+
+// CompressBatch compresses every inputFile with its own qzip invocation, keeping the original files intact,
+// so a bad file only fails itself. Client.Parallelism invocations run at a time.
+//
+// The returned report is never nil: Succeeded and Failed tell which files were compressed, and
+// Report.Retry returns the files to pass to a later call. The error joins every per-file error.
+//
+// qzip -k file1; qzip -k file2 ...
+//
+// 多文件压缩，按文件报告成功或失败
+func (c *Client) CompressBatch(opts BatchOptions, inputFiles ...string) (*Report, error) {
+	return c.runBatch(opts, true, inputFiles)
+}
+
+// DecompressBatch decompresses every inputFile with its own qzip invocation, removing each source
+// once it was decompressed successfully, like DecompressFiles.
+//
+// See CompressBatch for the report and error semantics.
+//
+// qzip -d file1; qzip -d file2 ...
+//
+// 多文件解压，按文件报告成功或失败
+func (c *Client) DecompressBatch(opts BatchOptions, inputFiles ...string) (*Report, error) {
+	return c.runBatch(opts, false, inputFiles)
+}
+
+// CompressBatch compresses inputFiles one by one with DefaultClient, see Client.CompressBatch.
+func CompressBatch(opts BatchOptions, inputFiles ...string) (*Report, error) {
+	return DefaultClient.CompressBatch(opts, inputFiles...)
+}
+
+// DecompressBatch decompresses inputFiles one by one with DefaultClient, see Client.DecompressBatch.
+func DecompressBatch(opts BatchOptions, inputFiles ...string) (*Report, error) {
+	return DefaultClient.DecompressBatch(opts, inputFiles...)
+}
+
+// 逐个文件执行qzip，记录每个文件的成功或失败
+func (c *Client) runBatch(opts BatchOptions, compression bool, inputFiles []string) (*Report, error) {
+	report := &Report{}
+	err := c.runEach(report, opts, inputFiles, func(inputFile string) (internal.QzipCommand, error) {
+		cmd := c.qzipCommand()
+		cmd.Compression = compression
+		// 与 CompressFiles/DecompressFiles 保持一致：压缩保留源文件，解压删除源文件
+		cmd.KeepSource = compression
+		cmd.InputFile = append(cmd.InputFile, inputFile)
+		return cmd, nil
+	})
+	return report, err
+}
+
+// 为每个输入文件构建并执行一次qzip命令，以 Parallelism 个命令并行执行，见 forEach
+func (c *Client) runEach(report *Report, opts BatchOptions, inputFiles []string, build func(inputFile string) (internal.QzipCommand, error)) error {
+	return c.forEach(report, opts, inputFiles, func(r *Report, inputFile string) error {
+		cmd, err := build(inputFile)
+		if err != nil {
+			return err
+		}
+		return c.runQzip(r, cmd)
+	})
+}
+
+// 对每个输入文件执行一次 run，以 Parallelism 个并行执行
+//
+// 每个文件的成功或失败记录在 report 中，run 写入各自 Report 的结果与执行计划按输入顺序合并；
+// fail-fast 时第一个失败之后不再启动新的文件，未启动的文件记录在 Report.Remaining 中
+func (c *Client) forEach(report *Report, opts BatchOptions, inputFiles []string, run func(r *Report, inputFile string) error) error {
+	parallelism := c.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	reports := make([]Report, len(inputFiles))
+	errs := make([]error, len(inputFiles))
+	started := make([]bool, len(inputFiles))
+	var failed atomic.Bool
+	// 信号量限制同时运行的命令数；获取到信号量时之前的命令可能已经结束，再判断是否需要停止
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range inputFiles {
+		sem <- struct{}{}
+		if failed.Load() && !opts.ContinueOnError {
+			<-sem
+			break
+		}
+		started[i] = true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := run(&reports[i], inputFiles[i]); err != nil {
+				errs[i] = err
+				failed.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var joined []error
+	for i, inputFile := range inputFiles {
+		if !started[i] {
+			report.Remaining = append(report.Remaining, inputFile)
+			continue
+		}
+		report.merge(&reports[i])
+		if errs[i] != nil {
+			report.addFailure(inputFile, errs[i])
+			joined = append(joined, fmt.Errorf("%s: %w", inputFile, errs[i]))
+			continue
+		}
+		report.Succeeded = append(report.Succeeded, inputFile)
+	}
+	return errors.Join(joined...)
+}
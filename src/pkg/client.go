@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// ExecConfig configures the qzip/tar/service binaries and the environment of the child processes,
+// see internal.ExecConfig.
+type ExecConfig = internal.ExecConfig
+
+// Client holds the settings shared by every compress/decompress operation,
+// such as the qzip/tar binaries and the environment passed to them.
+//
+// The package level functions (CompressFile, DecompressFiles, ...) use DefaultClient.
+//
+// 保存调用 qzip/tar 时使用的公共配置
+type Client struct {
+	// 子进程执行配置：qzip/tar/service 可执行文件路径以及环境变量
+	Exec ExecConfig
+	// DryRun 为 true 时只返回执行计划（见 Report.Plans），不执行 qzip/tar，也不会删除任何文件
+	DryRun bool
+	// 日志输出，记录执行的命令、文件、耗时、设备等结构化字段；为 nil 时不输出任何日志
+	Logger *slog.Logger
+	// 多文件操作拆分为多次 qzip 调用时同时运行的调用数，小于 1 时按 1 处理
+	Parallelism int
+	// 单次调用命令行参数与环境变量的总长度上限（字节），为 0 时使用系统限制
+	ArgMax int
+	// 为 true 时输出先写入目标旁边的临时目录，fsync 后重命名到最终路径，失败或取消时删除不完整的文件；
+	// 此时每个文件单独调用一次 qzip
+	Atomic bool
+	// 输出文件已经存在时的处理策略，默认返回 ErrOutputExists；对单文件、多文件、目录与 tar 操作同样生效，
+	// 每个文件的处理结果记录在 Result.Decision 与 Report.Collisions 中
+	Collision CollisionPolicy
+	// 不为 nil 时，不保留源文件的操作（如 DecompressFiles）只有在输出通过校验之后才删除源文件：
+	// 在 Go 中解压 gzip 或 LZ4s 数据并与另一端比较 CRC32 与大小。无法校验的格式（LZ4）保留源文件并记录在 Report.Unverified 中
+	SafeDelete *SafeDeleteOptions
+	// 压缩输出文件的命名模板，如 {dir}/{stem}.{date}{ext}，占位符见 internal.RenderNameTemplate；
+	// 为空时在源文件名后追加算法对应的后缀（.gz/.lz4/.lz4s）。设置后每个文件单独调用一次 qzip
+	NameTemplate string
+	// 为 true 时 gzip/gzipext/LZ4s 文件在 Go 中解压（见 gzipext 与 lz4s 包），不需要 qzip 与 QAT 设备，此时每个文件单独处理；
+	// 其他格式以及压缩仍然使用 qzip
+	Software bool
+	// 不为 nil 时，压缩操作（Compress* 与 CompressTree）完成后写入校验清单，记录源文件与压缩文件的 SHA-256，
+	// 见 VerifyManifest；DryRun 时不写入
+	Manifest *ManifestOptions
+	// 不为 nil 时使用 ed25519 为压缩输出与清单签名，并在解压 tar 归档前验证签名，见 SignatureOptions
+	Signature *SignatureOptions
+	// 不为 nil 时 CompressDictoryByTar 将归档加密后写入 .enc 文件，解压、校验与查看加密的归档时使用其中的密钥，见 EncryptionOptions
+	Encryption *EncryptionOptions
+	// 为 true 时 tar 压缩生成可复现的 tar 数据流：条目按名称排序，修改时间、属主与权限统一；
+	// 压缩后的 gzip 头（修改时间、OS 字节与 QZ 扩展字段）由 qzip 写入，不保证相同；加密的归档使用随机 nonce，不可复现
+	Reproducible bool
+	// 取消正在执行的操作，见 WithContext
+	ctx context.Context
+}
+
+// DefaultClient is the Client used by the package level functions.
+var DefaultClient = NewClient()
+
+// NewClient returns a Client that looks up qzip/tar in PATH and lets the
+// child processes inherit the current environment.
+func NewClient() *Client {
+	return &Client{
+		Exec:        internal.GetDefaultExecConfig(),
+		Parallelism: 1,
+	}
+}
+
+// WithContext returns a shallow copy of c whose operations stop when ctx is done: the running
+// qzip/tar processes are killed and, with Atomic, their partial outputs are removed.
+//
+// 返回使用 ctx 的 Client 副本
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// 获取操作使用的 context，未设置时为 context.Background()
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// 获取使用当前配置的默认qzip命令
+func (c *Client) qzipCommand() internal.QzipCommand {
+	cmd := internal.GetDefaultQzipCommand()
+	cmd.Exec = c.Exec
+	cmd.Logger = c.Logger
+	cmd.Context = c.ctx
+	cmd.NameTemplate = c.NameTemplate
+	return cmd
+}
+
+// 获取使用当前配置的默认tar命令
+func (c *Client) tarCommand() internal.TarCommand {
+	cmd := internal.GetDefaultTarCommand()
+	cmd.Exec = c.Exec
+	cmd.Logger = c.Logger
+	cmd.Context = c.ctx
+	cmd.Reproducible = c.Reproducible
+	return cmd
+}
+
+// 获取可用的 Logger，未设置时不输出任何日志
+func (c *Client) logger() *slog.Logger {
+	return internal.LoggerOf(c.Logger)
+}
+
+// 执行qzip命令，否则记录每个文件的处理结果；DryRun 时只记录执行计划，计划与实际执行的命令相同
+func (c *Client) runQzip(report *Report, cmd internal.QzipCommand) error {
+	// 解压时根据输入格式选择选项，拒绝未压缩的输入
+	cmd, mixed, err := detectDecompression(cmd)
+	if err != nil {
+		return err
+	}
+	// 格式不同的文件需要不同的选项
+	if c.runsPerFile(cmd) || mixed {
+		return c.runQzipAtomic(report, cmd)
+	}
+	// 执行前记录输入文件大小，不保留源文件时输入文件会被删除
+	targets, err := internal.QzipTargets(cmd)
+	if err != nil {
+		return err
+	}
+	decisions := make([]Decision, len(targets))
+	for i, target := range targets {
+		if target.Output == "" || !internal.FileIsExist(target.Output) {
+			decisions[i] = DecisionCreated
+			continue
+		}
+		switch c.Collision {
+		case CollisionOverwrite:
+			decisions[i] = DecisionOverwritten
+		case CollisionSkip, CollisionRename:
+			// 一次 qzip 调用无法跳过或重命名单个文件，改为逐个文件处理
+			return c.runQzipAtomic(report, cmd)
+		default:
+			return fmt.Errorf("%w: %s", ErrOutputExists, target.Output)
+		}
+	}
+	if c.Collision == CollisionOverwrite {
+		// qzip -f 强制覆盖已存在的输出文件
+		cmd.Options = append(append([]string(nil), cmd.Options...), "-f")
+	}
+	// 安全删除：由 qzip 保留源文件，校验输出后再删除
+	safeDelete := !cmd.KeepSource && c.SafeDelete != nil
+	if safeDelete {
+		cmd.KeepSource = true
+	}
+	if c.DryRun {
+		plan, err := internal.PlanQzipCommand(cmd)
+		if err != nil {
+			return err
+		}
+		if safeDelete {
+			// 校验输出后删除源文件
+			for _, target := range targets {
+				plan.Deletes = append(plan.Deletes, target.Input)
+			}
+		}
+		report.Plans = append(report.Plans, plan)
+		return nil
+	}
+	inputSizes := make([]int64, len(targets))
+	for i, target := range targets {
+		inputSizes[i] = sizeOf(target.Input)
+	}
+	start := time.Now()
+	output, err := internal.RunQzipCommand(cmd)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, result := range qzipResults(cmd, targets, inputSizes, output, time.Since(start)) {
+		result.Decision = decisions[i]
+		if safeDelete {
+			if err := c.safeRemove(cmd.Compression, &result); err != nil {
+				errs = append(errs, err)
+			} else if !result.Verified {
+				report.Unverified = append(report.Unverified, result.Input)
+			}
+		}
+		report.Results = append(report.Results, result)
+		report.addCollision(targets[i].Output, targets[i].Output, decisions[i])
+	}
+	return errors.Join(errs...)
+}
+
+// 是否需要逐个文件处理（见 runQzipAtomic）：Atomic；qzip 只能在源文件名后追加后缀，按模板命名时需要逐个文件使用 -o；
+// Software 模式下的解压需要逐个文件判断格式
+func (c *Client) runsPerFile(cmd internal.QzipCommand) bool {
+	return c.Atomic || (cmd.Compression && cmd.NameTemplate != "") || (!cmd.Compression && c.Software)
+}
+
+// 执行多文件的qzip命令：参数过长时按 ArgMax 拆分为多次调用，并以 Parallelism 个调用并行执行
+//
+// 各次调用的结果按输入顺序合并到 report 中，失败时返回所有失败调用的错误
+func (c *Client) runQzipChunks(report *Report, cmd internal.QzipCommand) error {
+	groups := cmd.SplitInputFiles(c.ArgMax)
+	// 逐个文件处理时不需要拆分
+	if len(groups) <= 1 || c.runsPerFile(cmd) {
+		return c.runQzip(report, cmd)
+	}
+	c.logger().Info("splitting qzip invocation", "file_count", len(cmd.InputFile), "batch_count", len(groups))
+
+	parallelism := c.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	reports := make([]Report, len(groups))
+	errs := make([]error, len(groups))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism && w < len(groups); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				chunk := cmd
+				chunk.Options = append([]string(nil), cmd.Options...)
+				chunk.InputFile = groups[i]
+				errs[i] = c.runQzip(&reports[i], chunk)
+			}
+		}()
+	}
+	for i := range groups {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := range reports {
+		report.merge(&reports[i])
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("batch %d/%d: %w", i+1, len(groups), errs[i])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 执行tar命令并记录归档结果；DryRun 时只记录执行计划，计划与实际执行的命令相同
+func (c *Client) runTar(report *Report, cmd internal.TarCommand) error {
+	if c.Atomic || (!cmd.Compression && c.Collision == CollisionRename) {
+		return c.runTarAtomic(report, cmd)
+	}
+	decision := DecisionCreated
+	if cmd.Compression {
+		archive, d, err := c.resolveOutput(cmd.ArchiveFile)
+		if err != nil {
+			return err
+		}
+		report.addCollision(cmd.ArchiveFile, archive, d)
+		if d == DecisionSkipped {
+			if !c.DryRun {
+				report.Results = append(report.Results, Result{Input: strings.Join(cmd.InputFile, " "), Output: archive, Decision: d})
+			}
+			return nil
+		}
+		cmd.ArchiveFile, decision = archive, d
+	} else {
+		// 解压时由 tar 处理已存在的文件，无法得到每个文件的处理结果
+		cmd.Options = append(append([]string(nil), cmd.Options...), tarCollisionOption(c.Collision))
+	}
+	if c.DryRun {
+		plan, err := internal.PlanTarCommand(cmd)
+		if err != nil {
+			return err
+		}
+		report.Plans = append(report.Plans, plan)
+		return nil
+	}
+	start := time.Now()
+	output, err := internal.RunTarCommand(cmd)
+	if err != nil {
+		return err
+	}
+	result := tarResult(cmd, output, time.Since(start))
+	result.Decision = decision
+	report.Results = append(report.Results, result)
+	return nil
+}
+
+// 解压时 tar 处理已存在文件的选项
+func tarCollisionOption(policy CollisionPolicy) string {
+	switch policy {
+	case CollisionOverwrite:
+		return "--overwrite"
+	case CollisionSkip:
+		return "--skip-old-files"
+	default:
+		return "--keep-old-files"
+	}
+}
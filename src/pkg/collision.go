@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CollisionPolicy decides what happens when an output file already exists.
+//
+// 输出文件已存在时的处理策略
+type CollisionPolicy int
+
+const (
+	// 返回 ErrOutputExists，不写入任何输出（默认）
+	CollisionFail CollisionPolicy = iota
+	// 覆盖已存在的文件
+	CollisionOverwrite
+	// 跳过该文件，保留已存在的输出与源文件
+	CollisionSkip
+	// 在扩展名之前加上数字后缀写入新文件：a.log.gz -> a.log.1.gz
+	CollisionRename
+)
+
+// Decision tells what was done with one output.
+//
+// 对单个输出文件的处理结果
+type Decision string
+
+const (
+	// 输出文件不存在，直接写入
+	DecisionCreated Decision = "created"
+	// 覆盖了已存在的文件
+	DecisionOverwritten Decision = "overwritten"
+	// 输出文件已存在，跳过
+	DecisionSkipped Decision = "skipped"
+	// 输出文件已存在，写入了带数字后缀的新文件
+	DecisionRenamed Decision = "renamed"
+)
+
+// ErrOutputExists is returned, wrapped with the output path, when an output already exists
+// and the policy is CollisionFail.
+var ErrOutputExists = errors.New("output already exists")
+
+// Collision describes an output that already existed and what was done about it.
+//
+// 已存在的输出文件及其处理结果
+type Collision struct {
+	// 预期的输出路径
+	Path string
+	// 实际写入的路径，跳过时与 Path 相同
+	Output string
+	// 处理结果
+	Decision Decision
+}
+
+func (p CollisionPolicy) String() string {
+	switch p {
+	case CollisionOverwrite:
+		return "overwrite"
+	case CollisionSkip:
+		return "skip"
+	case CollisionRename:
+		return "rename"
+	default:
+		return "fail"
+	}
+}
+
+// 按照策略决定输出路径：返回实际写入的路径与处理结果
+func (c *Client) resolveOutput(path string) (string, Decision, error) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path, DecisionCreated, nil
+	} else if err != nil {
+		return "", "", err
+	}
+	switch c.Collision {
+	case CollisionOverwrite:
+		return path, DecisionOverwritten, nil
+	case CollisionSkip:
+		return path, DecisionSkipped, nil
+	case CollisionRename:
+		free, err := freeName(path)
+		return free, DecisionRenamed, err
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrOutputExists, path)
+	}
+}
+
+// 查找不存在的文件名：在最后一个扩展名之前加上数字后缀，a.log.gz -> a.log.1.gz，a -> a.1
+func freeName(path string) (string, error) {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// 以点开头且没有其他扩展名的文件，如 .bashrc
+		stem, ext = base, ""
+	}
+	for i := 1; i < 10000; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s.%d%s", stem, i, ext))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", path)
+}
+
+// 记录已存在的输出文件及其处理结果
+func (r *Report) addCollision(path, output string, decision Decision) {
+	if decision == DecisionCreated || decision == "" {
+		return
+	}
+	r.Collisions = append(r.Collisions, Collision{Path: path, Output: output, Decision: decision})
+}
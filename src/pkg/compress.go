@@ -2,21 +2,32 @@ package pkg
 
 import (
 	"strings"
-
-	"github.com/ordinary-xiyv/qzipgo/src/internal"
 )
 
 // qzip -k filepath 测试压缩
 // output:Executing command: /usr/local/bin/qzip -k /tmp/test.txt
-func CompressFile(inputFile string) error {
-	cmd := internal.GetDefaultQzipCommand()
+func (c *Client) CompressFile(inputFile string) (*Report, error) {
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	cmd := c.qzipCommand()
 	cmd.KeepSource = true
 	cmd.InputFile = append(cmd.InputFile, inputFile)
 
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
-	return nil
+	if err := c.finishCompress(report, false); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressFile compresses inputFile with DefaultClient, see Client.CompressFile.
+func CompressFile(inputFile string) error {
+	_, err := DefaultClient.CompressFile(inputFile)
+	return err
 }
 
 // CompressWithOutputFile compresses the given inputFile and stores the output in the given outputFile,
@@ -30,9 +41,13 @@ func CompressFile(inputFile string) error {
 // qzip -k -o outputFile inputFile
 //
 // 单文件压缩并指定输出名称
-func CompressWithOutputFile(inputFile, outputFile string) error {
+func (c *Client) CompressWithOutputFile(inputFile, outputFile string) (*Report, error) {
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the output file
 	cmd.KeepSource = true
@@ -41,12 +56,22 @@ func CompressWithOutputFile(inputFile, outputFile string) error {
 	cmd.OutputFile = outputFile
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	if err := c.finishCompress(report, false); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressWithOutputFile compresses inputFile into outputFile with DefaultClient,
+// see Client.CompressWithOutputFile.
+func CompressWithOutputFile(inputFile, outputFile string) error {
+	_, err := DefaultClient.CompressWithOutputFile(inputFile, outputFile)
+	return err
 }
 
 // CompressDictory compresses the given directory, keeping the original directory intact.
@@ -58,9 +83,13 @@ func CompressWithOutputFile(inputFile, outputFile string) error {
 // qzip -k -R directory
 //
 // 目录递归压缩
-func CompressDictoryByEveryFile(inputFile string) error {
+func (c *Client) CompressDictoryByEveryFile(inputFile string) (*Report, error) {
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the directory true
 	cmd.KeepSource = true
@@ -69,12 +98,22 @@ func CompressDictoryByEveryFile(inputFile string) error {
 	cmd.IsDirctory = true
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	if err := c.finishCompress(report, false); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressDictoryByEveryFile compresses every file of the directory with DefaultClient,
+// see Client.CompressDictoryByEveryFile.
+func CompressDictoryByEveryFile(inputFile string) error {
+	_, err := DefaultClient.CompressDictoryByEveryFile(inputFile)
+	return err
 }
 
 // CompressFiles compresses the given inputFiles, keeping the original files intact.
@@ -83,12 +122,19 @@ func CompressDictoryByEveryFile(inputFile string) error {
 //
 // The function returns an error if something goes wrong while compressing the files.
 //
+// Large file lists are split into several qzip invocations that fit the system argument limit
+// (Client.ArgMax), run Client.Parallelism at a time, and merged into one report.
+//
 // qzip -k file1 file2 ...
 //
 // 多文件压缩
-func CompressFiles(inputFiles ...string) error {
+func (c *Client) CompressFiles(inputFiles ...string) (*Report, error) {
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input files, keep the original files and set the directory false
 	cmd.KeepSource = true
@@ -97,12 +143,22 @@ func CompressFiles(inputFiles ...string) error {
 	cmd.IsDirctory = false
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	// 文件过多时拆分为多次调用，失败时仍返回已成功部分的结果
+	if err := c.runQzipChunks(report, cmd); err != nil {
+		return report, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	if err := c.finishCompress(report, false); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressFiles compresses inputFiles with DefaultClient, see Client.CompressFiles.
+func CompressFiles(inputFiles ...string) error {
+	_, err := DefaultClient.CompressFiles(inputFiles...)
+	return err
 }
 
 // CompressFilesWithBusyPool compresses the given inputFiles, keeping the original files intact, using
@@ -115,9 +171,13 @@ func CompressFiles(inputFiles ...string) error {
 // qzip -k -P busy file1 file2 ...
 //
 // 目录多文件压缩，忙轮询
-func CompressDictoryWithBusyPoll(inputDirectory string) error {
+func (c *Client) CompressDictoryWithBusyPoll(inputDirectory string) (*Report, error) {
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input files, keep the original files and set the directory false
 	cmd.KeepSource = true
@@ -129,34 +189,84 @@ func CompressDictoryWithBusyPoll(inputDirectory string) error {
 	cmd.BusyPoll = true
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
+	}
+
+	// Return the report if everything went well
+	if err := c.finishCompress(report, false); err != nil {
+		return report, err
 	}
+	return report, nil
+}
 
-	// Return nil if everything went well
-	return nil
+// CompressDictoryWithBusyPoll compresses the directory using busy polling with DefaultClient,
+// see Client.CompressDictoryWithBusyPoll.
+func CompressDictoryWithBusyPoll(inputDirectory string) error {
+	_, err := DefaultClient.CompressDictoryWithBusyPoll(inputDirectory)
+	return err
 }
 
 // eg: tar -cvf mydir.tgz -I "qzip" ./test_files/
 //
 // output: mydir.tgz
 //
+// With Client.Encryption set the compressed archive is encrypted on the fly and written to
+// mydir.tgz.enc, see the crypt package for the format.
+//
 // 使用tar归档文件对目录进行  整体 压缩
-func CompressDictoryByTar(inputDirectory, outputFile string) error {
-	cmd := internal.GetDefaultTarCommand()
+func (c *Client) CompressDictoryByTar(inputDirectory, outputFile string) (*Report, error) {
+	report := &Report{}
+	cmd := c.tarCommand()
 	cmd.Compression = true
 	if outputFile == "" {
 		outputFile = inputDirectory
 	}
-	// 检查outputFile是否以.tgz或者.tar.gz结尾，如果不是，加上.tgz
+	// 这里的归档文件最后会成为压缩后的文件名，故直接将  归档  文件名设置为 输出 文件名
+	cmd.ArchiveFile = c.archiveName(outputFile)
+	cmd.InputFile = append(cmd.InputFile, inputDirectory)
+	run := c.runTar
+	if c.Encryption != nil {
+		run = c.runTarEncrypted
+	}
+	if err := run(report, cmd); err != nil {
+		return nil, err
+	}
+	if err := c.finishCompress(report, true); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressDictoryByTar archives the directory into outputFile with DefaultClient,
+// see Client.CompressDictoryByTar.
+func CompressDictoryByTar(inputDirectory, outputFile string) error {
+	_, err := DefaultClient.CompressDictoryByTar(inputDirectory, outputFile)
+	return err
+}
+
+// tar 归档的文件名：不以 .tgz 或 .tar.gz 结尾时加上 .tgz，加密时再加上 .enc
+func (c *Client) archiveName(outputFile string) string {
 	if !strings.HasSuffix(outputFile, ".tgz") && !strings.HasSuffix(outputFile, ".tar.gz") {
 		outputFile = outputFile + ".tgz"
 	}
-	// 这里的归档文件最后会成为压缩后的文件名，故直接将  归档  文件名设置为 输出 文件名
-	cmd.ArchiveFile = outputFile
-	cmd.InputFile = append(cmd.InputFile, inputDirectory)
-	if err := internal.ExecuteTarCommand(cmd); err != nil {
+	if c.Encryption != nil && !strings.HasSuffix(outputFile, EncryptedExtension) {
+		outputFile += EncryptedExtension
+	}
+	return outputFile
+}
+
+// 压缩完成后写入校验清单，并为输出与清单签名；archive 表示输出为 tar 归档，否则为逐个文件压缩
+func (c *Client) finishCompress(report *Report, archive bool) error {
+	var manifestPath string
+	var err error
+	if archive {
+		manifestPath, err = c.writeTarManifest(report)
+	} else {
+		manifestPath, err = c.writeFilesManifest(report)
+	}
+	if err != nil {
 		return err
 	}
-	return nil
+	return c.signOutputs(report, manifestPath)
 }
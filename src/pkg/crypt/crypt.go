@@ -0,0 +1,290 @@
+// Package crypt implements the chunked AES-GCM container used to encrypt compressed archives.
+//
+// The container starts with a header (integers are big endian):
+//
+//	+------+------+---------+------------+--------+--------+--------------+
+//	| "QZGE"      | version | chunk size | id len | key id | nonce prefix |
+//	| 4 bytes     | 1 byte  | 4 bytes    | 1 byte | n      | 8 bytes      |
+//	+------+------+---------+------------+--------+--------+--------------+
+//
+// followed by the chunks. Every chunk is the AES-GCM encryption of chunk size bytes of plaintext,
+// except the last one, which holds 0 to chunk size bytes; each adds a 16-byte tag. Chunk i uses the
+// nonce "nonce prefix || uint32(i)" and the additional data "header || final", where final is 1 for
+// the last chunk and 0 otherwise, so that modified headers, reordered chunks and truncated streams
+// fail to authenticate.
+//
+// 分块 AES-GCM 加密容器
+package crypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Magic starts every encrypted stream.
+const Magic = "QZGE"
+
+// 容器格式版本
+const version = 1
+
+// DefaultChunkSize is the plaintext size of a chunk when none is given.
+const DefaultChunkSize = 64 << 10
+
+// 块大小上限，避免损坏的头部导致过大的内存分配
+const maxChunkSize = 16 << 20
+
+// 随机 nonce 前缀的长度，与 4 字节的块序号组成 12 字节的 nonce
+const noncePrefixSize = 8
+
+var (
+	// ErrFormat is returned when a stream does not start with a valid header.
+	ErrFormat = errors.New("crypt: invalid header")
+	// ErrAuth is returned when a chunk fails to authenticate: the data was modified or truncated,
+	// or the key is wrong.
+	ErrAuth = errors.New("crypt: message authentication failed")
+	// ErrUnknownKey is returned by key providers for a key id they do not hold.
+	ErrUnknownKey = errors.New("crypt: unknown key")
+)
+
+// KeyProvider supplies the AES keys (16, 24 or 32 bytes) used to encrypt and decrypt streams.
+//
+// 密钥来源
+type KeyProvider interface {
+	// EncryptionKey returns the key used for new streams and its id, recorded in the header.
+	EncryptionKey() (id string, key []byte, err error)
+	// DecryptionKey returns the key with the given id, or an error wrapping ErrUnknownKey.
+	DecryptionKey(id string) ([]byte, error)
+}
+
+// 创建 AES-GCM
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 第 i 个块的 nonce
+func nonce(prefix []byte, i uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte(nil), prefix...), i)
+}
+
+// 第 i 个块的附加数据：头部与是否为最后一个块
+func additionalData(header []byte, final bool) []byte {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	return append(header[:len(header):len(header)], flag)
+}
+
+// Writer encrypts the data written to it into an underlying writer.
+//
+// 加密写入器
+type Writer struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	buf       []byte
+	counter   uint32
+	closed    bool
+	err       error
+}
+
+// NewWriter writes the header to w and returns a Writer that encrypts with the current key of keys,
+// chunkSize bytes per chunk (DefaultChunkSize when chunkSize < 1). Close must be called to write the
+// last chunk; it does not close w.
+func NewWriter(w io.Writer, keys KeyProvider, chunkSize int) (*Writer, error) {
+	if chunkSize < 1 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("crypt: chunk size %d exceeds %d", chunkSize, maxChunkSize)
+	}
+	id, key, err := keys.EncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > math.MaxUint8 {
+		return nil, fmt.Errorf("crypt: key id longer than %d bytes", math.MaxUint8)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header := append([]byte(Magic), version)
+	header = binary.BigEndian.AppendUint32(header, uint32(chunkSize))
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header, prefix: prefix, chunkSize: chunkSize}, nil
+}
+
+// Write encrypts p; full chunks are written once more data follows them.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("crypt: write after close")
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	z.buf = append(z.buf, p...)
+	// 缓冲区正好是一个完整的块时可能是最后一个块，等到有更多数据或 Close 时再写入
+	for len(z.buf) > z.chunkSize {
+		if err := z.seal(z.buf[:z.chunkSize], false); err != nil {
+			return 0, err
+		}
+		z.buf = append(z.buf[:0], z.buf[z.chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed || z.err != nil {
+		return z.err
+	}
+	z.closed = true
+	err := z.seal(z.buf, true)
+	z.buf = nil
+	return err
+}
+
+// 加密并写入一个块
+func (z *Writer) seal(chunk []byte, final bool) error {
+	if z.counter == math.MaxUint32 {
+		z.err = errors.New("crypt: too many chunks")
+		return z.err
+	}
+	sealed := z.aead.Seal(nil, nonce(z.prefix, z.counter), chunk, additionalData(z.header, final))
+	z.counter++
+	if _, err := z.w.Write(sealed); err != nil {
+		z.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader decrypts a stream written by Writer.
+//
+// 解密读取器
+type Reader struct {
+	// 头部记录的密钥 ID
+	KeyID     string
+	r         *bufio.Reader
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	chunk     []byte
+	buf       []byte
+	counter   uint32
+	done      bool
+	err       error
+}
+
+// NewReader reads the header from r and returns a Reader that decrypts the stream with the key
+// of keys named in the header. Every chunk is authenticated before its plaintext is returned.
+func NewReader(r io.Reader, keys KeyProvider) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(Magic)+1+4+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrFormat)
+	}
+	if v := header[len(Magic)]; v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, v)
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[len(Magic)+1:]))
+	if chunkSize < 1 || chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("%w: invalid chunk size %d", ErrFormat, chunkSize)
+	}
+	rest := make([]byte, int(header[len(header)-1])+noncePrefixSize)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	header = append(header, rest...)
+	id := string(rest[:len(rest)-noncePrefixSize])
+	key, err := keys.DecryptionKey(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		KeyID:     id,
+		r:         br,
+		aead:      aead,
+		header:    header,
+		prefix:    rest[len(rest)-noncePrefixSize:],
+		chunkSize: chunkSize,
+		chunk:     make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Read returns decrypted data. It returns an error wrapping ErrAuth when a chunk was modified or
+// the stream was truncated.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.buf) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.done {
+			return 0, io.EOF
+		}
+		z.err = z.open()
+	}
+	n := copy(p, z.buf)
+	z.buf = z.buf[n:]
+	return n, nil
+}
+
+// 读取并解密下一个块：读取不到完整的块或之后没有更多数据时为最后一个块
+func (z *Reader) open() error {
+	n, err := io.ReadFull(z.r, z.chunk)
+	final := false
+	switch err {
+	case nil:
+		if _, err := z.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return err
+	}
+	if n < z.aead.Overhead() {
+		return fmt.Errorf("%w: truncated chunk %d", ErrAuth, z.counter)
+	}
+	plain, err := z.aead.Open(z.chunk[:0], nonce(z.prefix, z.counter), z.chunk[:n], additionalData(z.header, final))
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d", ErrAuth, z.counter)
+	}
+	z.counter++
+	z.buf = plain
+	z.done = final
+	return nil
+}
@@ -0,0 +1,93 @@
+package crypt
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileKeyProvider is a KeyProvider backed by a key file, see LoadKeyFile.
+//
+// 从密钥文件读取的密钥
+type FileKeyProvider struct {
+	// 按文件中的顺序排列的密钥 ID，第一个用于加密
+	ids  []string
+	keys map[string][]byte
+}
+
+// LoadKeyFile reads a key file: one key per line as "id key", the key being 16, 24 or 32 bytes
+// encoded in hex or standard base64. Empty lines and lines starting with # are ignored.
+//
+// The first key encrypts new streams; all keys can decrypt, so a key is rotated by adding the new
+// key at the top and keeping the old ones below for as long as their archives are kept.
+//
+// 读取密钥文件
+func LoadKeyFile(path string) (*FileKeyProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	provider := &FileKeyProvider{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"id key\"", path, n)
+		}
+		id := fields[0]
+		key, err := decodeKey(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if _, ok := provider.keys[id]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key id %s", path, n, id)
+		}
+		provider.ids = append(provider.ids, id)
+		provider.keys[id] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(provider.ids) == 0 {
+		return nil, fmt.Errorf("%s: no key", path)
+	}
+	return provider, nil
+}
+
+// 解码十六进制或 base64 编码的密钥，并检查 AES 密钥长度
+func decodeKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("key is neither hex nor base64")
+		}
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid AES key length %d", len(key))
+}
+
+// EncryptionKey returns the first key of the file.
+func (p *FileKeyProvider) EncryptionKey() (string, []byte, error) {
+	id := p.ids[0]
+	return id, p.keys[id], nil
+}
+
+// DecryptionKey returns the key with the given id.
+func (p *FileKeyProvider) DecryptionKey(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
@@ -1,24 +1,40 @@
 package pkg
 
 import (
+	"bytes"
 	"errors"
-	"strings"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/ordinary-xiyv/qzipgo/src/internal"
 )
 
+// ErrUnknownSuffix is returned, wrapped with the path, when a file to decompress has none of the
+// compressed suffixes .gz, .lz4 and .lz4s.
+var ErrUnknownSuffix = internal.ErrUnknownSuffix
+
 // qzip -d -k filepath 测试解压
 // output:Executing command: /usr/local/bin/qzip -d -k /tmp/test.txt
-func DecompressFile(inputFile string) error {
-	cmd := internal.GetDefaultQzipCommand()
+func (c *Client) DecompressFile(inputFile string) (*Report, error) {
+	report := &Report{}
+	cmd := c.qzipCommand()
 	cmd.KeepSource = true
 	cmd.Compression = false
 	cmd.InputFile = append(cmd.InputFile, inputFile)
 
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
-	return nil
+	return report, nil
+}
+
+// DecompressFile decompresses inputFile with DefaultClient, see Client.DecompressFile.
+func DecompressFile(inputFile string) error {
+	_, err := DefaultClient.DecompressFile(inputFile)
+	return err
 }
 
 // DecompressWithOutputFile decompresses the given inputFile and stores the output in the given outputFile,
@@ -30,9 +46,10 @@ func DecompressFile(inputFile string) error {
 // The function returns an error if something goes wrong while decompressing the file.
 //
 // qzip -k -o outputFile inputFile
-func DecompressWithOutputFile(inputFile, outputFile string) error {
+func (c *Client) DecompressWithOutputFile(inputFile, outputFile string) (*Report, error) {
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the output file
 	cmd.KeepSource = true
@@ -41,12 +58,19 @@ func DecompressWithOutputFile(inputFile, outputFile string) error {
 	cmd.OutputFile = outputFile
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	return report, nil
+}
+
+// DecompressWithOutputFile decompresses inputFile into outputFile with DefaultClient,
+// see Client.DecompressWithOutputFile.
+func DecompressWithOutputFile(inputFile, outputFile string) error {
+	_, err := DefaultClient.DecompressWithOutputFile(inputFile, outputFile)
+	return err
 }
 
 // DecompressDictory decompresses the given directory, keeping the original directory intact.
@@ -58,9 +82,10 @@ func DecompressWithOutputFile(inputFile, outputFile string) error {
 // qzip -k -R directory
 //
 // 解压目录下每一个压缩文件
-func DecompressDictoryByEveryFile(inputFile string) error {
+func (c *Client) DecompressDictoryByEveryFile(inputFile string) (*Report, error) {
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the directory true
 	cmd.KeepSource = false
@@ -71,12 +96,19 @@ func DecompressDictoryByEveryFile(inputFile string) error {
 	cmd.Recursive = true
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	return report, nil
+}
+
+// DecompressDictoryByEveryFile decompresses every file of the directory with DefaultClient,
+// see Client.DecompressDictoryByEveryFile.
+func DecompressDictoryByEveryFile(inputFile string) error {
+	_, err := DefaultClient.DecompressDictoryByEveryFile(inputFile)
+	return err
 }
 
 // DecompressFiles decompresses the given inputFiles, keeping the original files intact.
@@ -85,13 +117,17 @@ func DecompressDictoryByEveryFile(inputFile string) error {
 //
 // The function returns an error if something goes wrong while decompressing the files.
 //
+// Large file lists are split into several qzip invocations that fit the system argument limit
+// (Client.ArgMax), run Client.Parallelism at a time, and merged into one report.
+//
 // qzip -k file1 file2 ...
 //
 // 多文件解压
-func DecompressFiles(inputFiles ...string) error {
+func (c *Client) DecompressFiles(inputFiles ...string) (*Report, error) {
+	report := &Report{}
 
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the directory true
 	cmd.KeepSource = false
@@ -100,12 +136,19 @@ func DecompressFiles(inputFiles ...string) error {
 	cmd.InputFile = append(cmd.InputFile, inputFiles...)
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	// 文件过多时拆分为多次调用，失败时仍返回已成功部分的结果
+	if err := c.runQzipChunks(report, cmd); err != nil {
+		return report, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	return report, nil
+}
+
+// DecompressFiles decompresses inputFiles with DefaultClient, see Client.DecompressFiles.
+func DecompressFiles(inputFiles ...string) error {
+	_, err := DefaultClient.DecompressFiles(inputFiles...)
+	return err
 }
 
 // DecompressDictoryWithBusyPoll decompresses the given directory, keeping the original directory intact and using busy polling.
@@ -117,9 +160,10 @@ func DecompressFiles(inputFiles ...string) error {
 // qzip -k -P busy -R directory
 //
 // 解压目录下每一个压缩文件 忙轮询
-func DecompressDictoryWithBusyPoll(inputDirectory string) error {
+func (c *Client) DecompressDictoryWithBusyPoll(inputDirectory string) (*Report, error) {
+	report := &Report{}
 	// Create a new QzipCommand with the default configuration
-	cmd := internal.GetDefaultQzipCommand()
+	cmd := c.qzipCommand()
 
 	// Set the input file, keep the original file and set the directory true
 	cmd.KeepSource = false
@@ -132,12 +176,19 @@ func DecompressDictoryWithBusyPoll(inputDirectory string) error {
 	cmd.BusyPoll = true
 
 	// Execute the command and return the error if something goes wrong
-	if err := internal.ExecuteQzipCommand(cmd); err != nil {
-		return err
+	if err := c.runQzip(report, cmd); err != nil {
+		return nil, err
 	}
 
-	// Return nil if everything went well
-	return nil
+	// Return the report if everything went well
+	return report, nil
+}
+
+// DecompressDictoryWithBusyPoll decompresses the directory using busy polling with DefaultClient,
+// see Client.DecompressDictoryWithBusyPoll.
+func DecompressDictoryWithBusyPoll(inputDirectory string) error {
+	_, err := DefaultClient.DecompressDictoryWithBusyPoll(inputDirectory)
+	return err
 }
 
 // DecompressDictoryByTar decompresses the given inputFile using tar, and writes the contents to the given outputDirectory.
@@ -147,22 +198,140 @@ func DecompressDictoryWithBusyPoll(inputDirectory string) error {
 //
 // The function returns an error if something goes wrong while decompressing the file.
 //
-// If the input file does not have a .tgz or .tar.gz extension, the function will return an error.
-func DecompressDictoryByTar(inputFile, outputDirectory string) error {
-	cmd := internal.GetDefaultTarCommand()
+// If the input file is not a gzip compressed tar archive, whatever its extension, the function will
+// return an error. With Client.Signature.PublicKey set, an archive that does not match its detached
+// signature is rejected with ErrSignature before anything is extracted; the archive is then read
+// from the same open file and streamed to tar, so it cannot be swapped after the check.
+//
+// Archives encrypted by CompressDictoryByTar are decrypted with the keys of Client.Encryption. The
+// whole archive is authenticated before tar starts, so a tampered archive extracts nothing.
+func (c *Client) DecompressDictoryByTar(inputFile, outputDirectory string) (*Report, error) {
+	report := &Report{}
+	cmd := c.tarCommand()
 	cmd.Compression = false
 	if inputFile == "" {
-		return errors.New("input file is empty")
-	}
-	// 检查inputFile是否以.tgz或者.tar.gz结尾，如果不是则退出
-	if !strings.HasSuffix(inputFile, ".tgz") && !strings.HasSuffix(inputFile, ".tar.gz") {
-		return errors.New("input file is not tgz or tar.gz")
+		return nil, errors.New("input file is empty")
 	}
 	cmd.ArchiveFile = inputFile
 	cmd.OutputFile = outputDirectory
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 解压前验证签名，拒绝被修改的归档；签名验证与解压使用同一个打开的文件
+	if err := c.verifySignatureFile(inputFile, f); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	format, err := IdentifyReader(f)
+	encrypted := err == nil && format == FormatEncrypted
+	if encrypted || (c.Signature != nil && c.Signature.PublicKey != nil) {
+		if err := c.runTarFile(report, cmd, f, encrypted); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+	// 根据文件内容检查是否为 gzip 压缩的 tar 归档，不依赖 .tgz/.tar.gz 后缀
+	if err := isCompressedTar(inputFile); err != nil {
+		return nil, err
+	}
 	//cmd.InputFile = append(cmd.InputFile, inputFile)
-	if err := internal.ExecuteTarCommand(cmd); err != nil {
+	if err := c.runTar(report, cmd); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// DecompressDictoryByTar extracts inputFile into outputDirectory with DefaultClient,
+// see Client.DecompressDictoryByTar.
+func DecompressDictoryByTar(inputFile, outputDirectory string) error {
+	_, err := DefaultClient.DecompressDictoryByTar(inputFile, outputDirectory)
+	return err
+}
+
+// 从已打开的归档文件 f 解压 tar 归档，tar 从标准输入读取：decrypt 为 true 时先校验整个加密数据流，
+// 通过后再解密并解压，被修改的归档不会解压出任何文件；否则检查内容为 gzip 压缩的 tar 归档后直接解压
+//
+// 调用方在同一个文件上验证签名，验证与解压之间被替换的归档不会被解压
+func (c *Client) runTarFile(report *Report, cmd internal.TarCommand, f *os.File, decrypt bool) error {
+	archive := cmd.ArchiveFile
+	if cmd.OutputFile == "" {
+		cmd.OutputFile = filepath.Dir(archive)
+	}
+	stream := cmd
+	stream.ArchiveFile = internal.StreamArchive
+	// 由 tar 处理已存在的文件
+	stream.Options = append(append([]string(nil), cmd.Options...), tarCollisionOption(c.Collision))
+	if c.DryRun {
+		plan, err := internal.PlanTarCommand(stream)
+		if err != nil {
+			return err
+		}
+		plan.Inputs = []string{archive}
+		report.Plans = append(report.Plans, plan)
+		return nil
+	}
+
+	// 第一遍：检查格式，加密的归档校验每个块
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if decrypt {
+		src, format, err := c.decryptReader(f)
+		if err == nil {
+			_, err = io.Copy(io.Discard, src)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", archive, err)
+		}
+		if format != FormatGzip && format != FormatGzipExt {
+			return fmt.Errorf("%s is not an encrypted gzip compressed tar archive (content %s)", archive, format)
+		}
+	} else if err := isCompressedTarReader(archive, f); err != nil {
+		return err
+	}
+
+	// 第二遍：解密并解压
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
+	var src io.Reader = f
+	if decrypt {
+		var err error
+		if src, _, err = c.decryptReader(f); err != nil {
+			return fmt.Errorf("%s: %w", archive, err)
+		}
+	}
+	// 与 runTarAtomic 相同：先解压到输出目录中的临时目录，成功后再移动到输出目录
+	atomic := c.Atomic || c.Collision == CollisionRename
+	if atomic {
+		tmpDir, err := os.MkdirTemp(cmd.OutputFile, atomicTempPattern)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		stream.OutputFile = tmpDir
+		stream.Options = cmd.Options
+	}
+	process, err := internal.TarProcess(stream)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	process.Stdin, process.Stdout, process.Stderr = src, &stdout, &stderr
+	start := time.Now()
+	if err := c.runStreamProcess(process, &stderr, []string{archive}); err != nil {
+		return err
+	}
+	if atomic {
+		if err := c.commitTree(report, stream.OutputFile, cmd.OutputFile); err != nil {
+			return err
+		}
+	}
+	result := tarResult(cmd, stdout.String(), time.Since(start))
+	report.Results = append(report.Results, result)
 	return nil
 }
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/crypt"
+)
+
+// EncryptedExtension is appended to the name of encrypted archives.
+const EncryptedExtension = ".enc"
+
+// EncryptionOptions configures the encryption of the archives written by CompressDictoryByTar.
+//
+// 加密选项
+type EncryptionOptions struct {
+	// 密钥来源：加密使用其当前密钥，解密按头部记录的密钥 ID 查找，见 crypt.LoadKeyFile
+	Keys crypt.KeyProvider
+	// 每个加密块的明文大小，为 0 时使用 crypt.DefaultChunkSize
+	ChunkSize int
+}
+
+// 设置 Encryption 时将 write 写入的数据加密后再写入输出
+func (c *Client) encrypted(write func(w io.Writer) error) func(w io.Writer) error {
+	if c.Encryption == nil {
+		return write
+	}
+	return func(w io.Writer) error {
+		if c.Encryption.Keys == nil {
+			return errors.New("encryption without a key provider")
+		}
+		enc, err := crypt.NewWriter(w, c.Encryption.Keys, c.Encryption.ChunkSize)
+		if err != nil {
+			return err
+		}
+		if err := write(enc); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+}
+
+// 打开解密数据流，返回解密后的数据及其格式
+func (c *Client) decryptReader(r io.Reader) (io.Reader, Format, error) {
+	if c.Encryption == nil || c.Encryption.Keys == nil {
+		return nil, FormatEncrypted, errors.New("encrypted input but no key provider (Client.Encryption)")
+	}
+	zr, err := crypt.NewReader(r, c.Encryption.Keys)
+	if err != nil {
+		return nil, FormatEncrypted, err
+	}
+	br := bufio.NewReader(zr)
+	header, err := br.Peek(identifySize)
+	if err != nil && err != io.EOF {
+		return nil, FormatEncrypted, err
+	}
+	return br, identify(header), nil
+}
+
+// 压缩并加密 tar 归档：tar 将归档写入标准输出，加密后写入归档旁边的临时文件，成功后重命名为归档文件
+func (c *Client) runTarEncrypted(report *Report, cmd internal.TarCommand) error {
+	stream := cmd
+	stream.ArchiveFile = internal.StreamArchive
+	if c.DryRun {
+		plan, err := internal.PlanTarCommand(stream)
+		if err != nil {
+			return err
+		}
+		plan.Outputs = []string{cmd.ArchiveFile}
+		report.Plans = append(report.Plans, plan)
+		return nil
+	}
+	start := time.Now()
+	archive, decision, err := c.writeOutput(report, cmd.ArchiveFile, c.encrypted(func(w io.Writer) error {
+		process, err := internal.TarProcess(stream)
+		if err != nil {
+			return err
+		}
+		var stderr bytes.Buffer
+		process.Stdout, process.Stderr = w, &stderr
+		return c.runStreamProcess(process, &stderr, cmd.InputFile)
+	}))
+	if err != nil {
+		return err
+	}
+	cmd.ArchiveFile = archive
+	if decision == DecisionSkipped {
+		report.Results = append(report.Results, Result{Input: cmd.InputFile[0], Output: archive, Decision: decision})
+		return nil
+	}
+	result := tarResult(cmd, "", time.Since(start))
+	result.Decision = decision
+	report.Results = append(report.Results, result)
+	return nil
+}
@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnsafeEntry is returned by ExtractEntries for an entry that would be written outside of the
+// destination: an absolute name, a name containing "..", or a path through a symbolic link.
+var ErrUnsafeEntry = errors.New("unsafe archive entry")
+
+// ArchiveEntry describes one entry of a tar archive, see Client.ListArchive.
+//
+// tar 归档中的一个条目
+type ArchiveEntry struct {
+	// 条目名称，与归档中记录的一致（目录通常以 / 结尾）
+	Name string
+	// 类型与权限，如 fs.ModeDir、fs.ModeSymlink；硬链接为普通文件
+	Mode fs.FileMode
+	// 普通文件的大小
+	Size int64
+	// 修改时间
+	ModTime time.Time
+	// 属主与属组
+	Uid, Gid     int
+	Uname, Gname string
+	// 符号链接或硬链接的目标
+	Linkname string
+}
+
+// ListArchive returns the entries of a tar archive, compressed in any format Identify recognises
+// (decompressed as in Verify), encrypted (see Client.Encryption) or not compressed at all. Nothing is
+// written to disk. On error the entries read so far are returned with it.
+//
+// 列出 tar 归档中的条目
+func (c *Client) ListArchive(path string) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	err := c.readArchive(path, func(hdr *tar.Header, _ io.Reader) error {
+		entries = append(entries, ArchiveEntry{
+			Name:     hdr.Name,
+			Mode:     hdr.FileInfo().Mode(),
+			Size:     hdr.Size,
+			ModTime:  hdr.ModTime,
+			Uid:      hdr.Uid,
+			Gid:      hdr.Gid,
+			Uname:    hdr.Uname,
+			Gname:    hdr.Gname,
+			Linkname: hdr.Linkname,
+		})
+		return nil
+	})
+	return entries, err
+}
+
+// ListArchive returns the entries of a tar archive with DefaultClient, see Client.ListArchive.
+func ListArchive(path string) ([]ArchiveEntry, error) {
+	return DefaultClient.ListArchive(path)
+}
+
+// ExtractEntries extracts the entries of the tar archive at path that match one of patterns into dst,
+// streaming the decompressed archive instead of extracting it whole. dst is created if needed and
+// defaults to the directory of the archive.
+//
+// Patterns follow WalkOptions: a pattern containing "/" is matched against the entry name, any other
+// pattern against its last element. An entry is selected when it or one of its parent directories
+// matches, so "logs/app" extracts the whole directory. It is an error (wrapping fs.ErrNotExist) when
+// no entry matches.
+//
+// Entries that would land outside dst are rejected with ErrUnsafeEntry. Files are written to a
+// temporary file and renamed, existing files are handled by Client.Collision; devices and FIFOs are
+// skipped. A hard link is only extracted together with its target, which must precede it in the
+// archive and be selected too; otherwise an error wrapping fs.ErrNotExist names the missing target.
+// With Client.Signature.PublicKey set the archive signature is verified first; with DryRun nothing is
+// written and Report.Plans lists the paths that would be.
+//
+// 只解压 tar 归档中匹配的条目
+func (c *Client) ExtractEntries(path string, patterns []string, dst string) (*Report, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no pattern")
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if dst == "" {
+		dst = filepath.Dir(path)
+	}
+	if err := c.verifySignature(path); err != nil {
+		return nil, err
+	}
+	if !c.DryRun {
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{}
+	var outputs []string
+	// 已经解压的条目在 dst 中的路径，硬链接只能指向其中的条目
+	extracted := make(map[string]string)
+	var matched int
+	var written int64
+	start := time.Now()
+	err := c.readArchive(path, func(hdr *tar.Header, r io.Reader) error {
+		if !matchEntry(patterns, hdr.Name) {
+			return nil
+		}
+		matched++
+		target, err := entryPath(dst, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			if _, err := entryPath(dst, hdr.Linkname); err != nil {
+				return err
+			}
+			// 硬链接条目不包含数据，目标没有解压时无法创建
+			if _, ok := extracted[entryKey(hdr.Linkname)]; !ok {
+				return fmt.Errorf("%w: target %s of hard link %s was not extracted, add it to the patterns",
+					fs.ErrNotExist, hdr.Linkname, hdr.Name)
+			}
+		}
+		if c.DryRun {
+			outputs = append(outputs, target)
+			extracted[entryKey(hdr.Name)] = target
+			return nil
+		}
+		n, err := c.extractEntry(report, extracted, target, hdr, r)
+		written += n
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+	if matched == 0 {
+		return report, fmt.Errorf("%w: no entry of %s matches %s", fs.ErrNotExist, path, strings.Join(patterns, " "))
+	}
+	if c.DryRun {
+		report.Plans = append(report.Plans, Plan{Outputs: outputs})
+		return report, nil
+	}
+	report.Results = append(report.Results, Result{
+		Input:    path,
+		Output:   dst,
+		BytesIn:  sizeOf(path),
+		BytesOut: written,
+		Duration: time.Since(start),
+	})
+	return report, nil
+}
+
+// ExtractEntries extracts the matching entries of a tar archive with DefaultClient,
+// see Client.ExtractEntries.
+func ExtractEntries(path string, patterns []string, dst string) error {
+	_, err := DefaultClient.ExtractEntries(path, patterns, dst)
+	return err
+}
+
+// 依次读取 tar 归档的条目：压缩或加密的归档以数据流解压，读取全部条目后读取到结尾以检查解压是否成功
+func (c *Client) readArchive(path string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	var rc io.ReadCloser
+	format, err := Identify(path)
+	if err != nil {
+		return err
+	}
+	if format == FormatTar {
+		rc, err = os.Open(path)
+	} else {
+		rc, _, err = c.openDecompressed(path)
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// 条目或其所在的某个目录匹配任意模式时选中
+func matchEntry(patterns []string, name string) bool {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	for name != "" {
+		for _, pattern := range patterns {
+			if matchGlob(pattern, name) {
+				return true
+			}
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return false
+}
+
+// 条目名称的规范形式，用于查找硬链接的目标
+func entryKey(name string) string {
+	return path.Clean(name)
+}
+
+// 条目在 dst 中的路径：拒绝绝对路径、包含 .. 的名称，以及经过 dst 中已有符号链接的路径
+func entryPath(dst, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeEntry, name)
+	}
+	parts := strings.Split(clean, "/")
+	dir := dst
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s (through symbolic link %s)", ErrUnsafeEntry, name, dir)
+		}
+	}
+	return filepath.Join(dst, filepath.FromSlash(clean)), nil
+}
+
+// 写入单个条目，返回写入的字节数；写入的文件记录在 extracted 中
+func (c *Client) extractEntry(report *Report, extracted map[string]string, target string, hdr *tar.Header, r io.Reader) (int64, error) {
+	perm := hdr.FileInfo().Mode().Perm()
+	if hdr.Typeflag == tar.TypeDir {
+		// 不能经过 dst 中已有的符号链接创建目录
+		if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return 0, fmt.Errorf("%w: %s (symbolic link %s)", ErrUnsafeEntry, hdr.Name, target)
+		}
+		return 0, os.MkdirAll(target, perm|0o700)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		output, decision, err := c.writeOutput(report, target, func(w io.Writer) error {
+			_, err := io.Copy(w, r)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		extracted[entryKey(hdr.Name)] = output
+		if decision == DecisionSkipped {
+			return 0, nil
+		}
+		if err := os.Chmod(output, perm); err != nil {
+			return 0, err
+		}
+		return hdr.Size, os.Chtimes(output, hdr.ModTime, hdr.ModTime)
+	case tar.TypeSymlink, tar.TypeLink:
+		source := hdr.Linkname
+		if hdr.Typeflag == tar.TypeLink {
+			// 硬链接指向归档中已经解压的条目（可能按 Collision 重命名）
+			source = extracted[entryKey(hdr.Linkname)]
+		}
+		output, decision, err := c.resolveOutput(target)
+		if err != nil {
+			return 0, err
+		}
+		report.addCollision(target, output, decision)
+		switch decision {
+		case DecisionSkipped:
+			extracted[entryKey(hdr.Name)] = output
+			return 0, nil
+		case DecisionOverwritten:
+			if err := os.Remove(output); err != nil {
+				return 0, err
+			}
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			extracted[entryKey(hdr.Name)] = output
+			return 0, os.Link(source, output)
+		}
+		return 0, os.Symlink(source, output)
+	default:
+		c.logger().Warn("skipping archive entry", "entry", hdr.Name, "type", string(hdr.Typeflag))
+		return 0, nil
+	}
+}
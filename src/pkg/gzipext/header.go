@@ -0,0 +1,199 @@
+// Package gzipext reads the gzip streams written by qzip with the gzipext format (the default
+// QAT format, -O gzipext) without QAT hardware.
+//
+// A gzipext stream is a sequence of gzip members, one per compressed block. Every member carries a
+// "QZ" subfield in its FEXTRA header that records the uncompressed size of the block (ChunkSize) and
+// the size of its deflate payload (BlockSize):
+//
+//	+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+
+//	|1f |8b |08 |FLG|     MTIME     |XFL|OS | XLEN=12 |'Q'|'Z'| LEN=8 |  ChunkSize    |  BlockSize    |
+//	+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+---+
+//	| deflate payload (BlockSize bytes) ... | CRC32 | ISIZE |
+//
+// Because the payload sizes are known up front, the blocks are inflated in parallel. Members
+// without the QZ subfield (plain gzip) are inflated in order, so any gzip stream can be read.
+//
+// gzipext 格式的纯 Go 解码器
+package gzipext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrHeader is returned when the stream is not a valid gzip stream.
+	ErrHeader = errors.New("gzipext: invalid header")
+	// ErrChecksum is returned when a member does not match its CRC32, ISIZE or QZ sizes.
+	ErrChecksum = errors.New("gzipext: invalid checksum")
+)
+
+// MaxChunkSize is the largest QZ chunk accepted, the maximum hardware buffer size of QATzip
+// (QZ_HW_BUFF_MAX_SZ). Larger ChunkSize or BlockSize fields are rejected with ErrHeader so that
+// a corrupt header cannot make the reader allocate gigabytes.
+const MaxChunkSize = 2 << 20
+
+// 压缩后数据块大小的上限：不可压缩的数据经 deflate 后略大于原始大小
+const maxBlockSize = MaxChunkSize + MaxChunkSize/8
+
+// gzip 头部标志
+const (
+	flagText    = 1 << 0
+	flagHCRC    = 1 << 1
+	flagExtra   = 1 << 2
+	flagName    = 1 << 3
+	flagComment = 1 << 4
+)
+
+// Member describes one gzip member of a stream.
+//
+// gzip 成员（gzipext 的一个数据块）
+type Member struct {
+	// 成员在流中的偏移
+	Offset int64
+	// 头部长度
+	HeaderSize int64
+	// 原始文件名（FNAME）
+	Name string
+	// 注释（FCOMMENT）
+	Comment string
+	// 修改时间（MTIME），为 0 时为零值
+	ModTime time.Time
+	// 生成数据的操作系统（OS 字节）
+	OS byte
+	// 是否带有 QAT 的 QZ 扩展字段
+	QZ bool
+	// QZ 字段记录的未压缩大小
+	ChunkSize uint32
+	// QZ 字段记录的 deflate 数据大小
+	BlockSize uint32
+	// 尾部记录的未压缩数据 CRC32
+	CRC32 uint32
+	// 尾部记录的未压缩大小（模 2^32）
+	ISize uint32
+}
+
+// 记录偏移的读取器，实现 io.ByteReader 以免 flate 多读数据
+type countingReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func newCountingReader(r io.Reader) *countingReader {
+	return &countingReader{r: bufio.NewReaderSize(r, 1<<16)}
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.offset++
+	}
+	return b, err
+}
+
+// 是否已经到达流的末尾
+func (c *countingReader) atEOF() bool {
+	_, err := c.r.Peek(1)
+	return err == io.EOF
+}
+
+// 读取一个成员的头部
+func readHeader(r *countingReader) (Member, error) {
+	m := Member{Offset: r.offset}
+	var buf [10]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+	}
+	if buf[0] != 0x1f || buf[1] != 0x8b || buf[2] != 8 {
+		return m, fmt.Errorf("%w at offset %d: bad magic", ErrHeader, m.Offset)
+	}
+	flags := buf[3]
+	if mtime := binary.LittleEndian.Uint32(buf[4:8]); mtime > 0 {
+		m.ModTime = time.Unix(int64(mtime), 0)
+	}
+	m.OS = buf[9]
+	if flags&flagExtra != 0 {
+		if _, err := io.ReadFull(r, buf[:2]); err != nil {
+			return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+		}
+		extra := make([]byte, binary.LittleEndian.Uint16(buf[:2]))
+		if _, err := io.ReadFull(r, extra); err != nil {
+			return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+		}
+		parseExtra(&m, extra)
+		if m.QZ && (m.ChunkSize > MaxChunkSize || m.BlockSize > maxBlockSize) {
+			return m, fmt.Errorf("%w at offset %d: QZ chunk size %d, block size %d exceed the maximum %d",
+				ErrHeader, m.Offset, m.ChunkSize, m.BlockSize, MaxChunkSize)
+		}
+	}
+	var err error
+	if flags&flagName != 0 {
+		if m.Name, err = readString(r); err != nil {
+			return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+		}
+	}
+	if flags&flagComment != 0 {
+		if m.Comment, err = readString(r); err != nil {
+			return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+		}
+	}
+	if flags&flagHCRC != 0 {
+		if _, err := io.ReadFull(r, buf[:2]); err != nil {
+			return m, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+		}
+	}
+	m.HeaderSize = r.offset - m.Offset
+	return m, nil
+}
+
+// 解析 FEXTRA 中的子字段，查找 QZ 字段
+func parseExtra(m *Member, extra []byte) {
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			return
+		}
+		if extra[0] == 'Q' && extra[1] == 'Z' && size == 8 {
+			m.QZ = true
+			m.ChunkSize = binary.LittleEndian.Uint32(extra[4:8])
+			m.BlockSize = binary.LittleEndian.Uint32(extra[8:12])
+		}
+		extra = extra[4+size:]
+	}
+}
+
+// 读取以 0 结尾的 ISO 8859-1 字符串
+func readString(r *countingReader) (string, error) {
+	var b []rune
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, rune(c))
+	}
+}
+
+// 读取成员尾部的 CRC32 与 ISIZE
+func readTrailer(r *countingReader, m *Member) error {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return fmt.Errorf("%w at offset %d: truncated trailer: %v", ErrHeader, m.Offset, err)
+	}
+	m.CRC32 = binary.LittleEndian.Uint32(buf[:4])
+	m.ISize = binary.LittleEndian.Uint32(buf[4:])
+	return nil
+}
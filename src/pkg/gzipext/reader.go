@@ -0,0 +1,255 @@
+package gzipext
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// 一个待写出的成员：QZ 成员由 worker 解压；其他成员由读取的 goroutine 解压并通过 stream 直接写出，
+// 不在内存中保留整个成员
+type block struct {
+	member  Member
+	payload []byte
+	data    []byte
+	stream  *io.PipeReader
+	err     error
+	done    chan struct{}
+}
+
+// Scan reads the headers and trailers of every member of r without inflating the QZ blocks,
+// and returns them in stream order. Plain gzip members are inflated to find their end.
+//
+// 读取全部成员的头部与尾部信息
+func Scan(r io.Reader) ([]Member, error) {
+	cr := newCountingReader(r)
+	var members []Member
+	for len(members) == 0 || !cr.atEOF() {
+		m, err := readHeader(cr)
+		if err != nil {
+			return members, err
+		}
+		if m.QZ {
+			if _, err := io.CopyN(io.Discard, cr, int64(m.BlockSize)); err != nil {
+				return members, fmt.Errorf("%w at offset %d: truncated block: %v", ErrHeader, m.Offset, err)
+			}
+		} else {
+			start := cr.offset
+			zr := flate.NewReader(cr)
+			_, err := io.Copy(io.Discard, zr)
+			zr.Close()
+			if err != nil {
+				return members, fmt.Errorf("%w at offset %d: %v", ErrHeader, m.Offset, err)
+			}
+			m.BlockSize = uint32(cr.offset - start)
+		}
+		if err := readTrailer(cr, &m); err != nil {
+			return members, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// Decompress inflates the gzip or gzipext stream src into dst and returns the number of bytes
+// written. QZ blocks are inflated by concurrency goroutines (GOMAXPROCS when concurrency < 1) and
+// written in order; every member is checked against its CRC32, ISIZE and QZ sizes.
+//
+// 并行解压 gzipext 数据
+func Decompress(dst io.Writer, src io.Reader, concurrency int) (int64, error) {
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	jobs := make(chan *block, concurrency)
+	// 按流中的顺序排队，限制已读取但尚未写出的块数
+	order := make(chan *block, 2*concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				b.data, b.err = inflate(b.payload, int(b.member.ChunkSize))
+				if b.err == nil {
+					b.err = verify(b.member, b.data)
+				}
+				b.payload = nil
+				close(b.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		readBlocks(src, jobs, order, quit)
+	}()
+
+	var written int64
+	for b := range order {
+		<-b.done
+		if b.err != nil {
+			return written, b.err
+		}
+		if b.stream != nil {
+			// 之前的 QZ 块已经写出，按顺序写出普通 gzip 成员
+			n, err := io.Copy(dst, b.stream)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			continue
+		}
+		n, err := dst.Write(b.data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	wg.Wait()
+	return written, nil
+}
+
+// 读取全部成员：QZ 成员交给 worker，普通 gzip 成员直接解压，读取出错时以错误结束
+func readBlocks(src io.Reader, jobs, order chan<- *block, quit <-chan struct{}) {
+	cr := newCountingReader(src)
+	send := func(ch chan<- *block, b *block) bool {
+		select {
+		case ch <- b:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+	fail := func(err error) {
+		b := &block{err: err, done: make(chan struct{})}
+		close(b.done)
+		send(order, b)
+	}
+	for first := true; first || !cr.atEOF(); first = false {
+		m, err := readHeader(cr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		b := &block{member: m, done: make(chan struct{})}
+		if !m.QZ {
+			if !streamMember(cr, b, order, send, quit) {
+				return
+			}
+			continue
+		}
+		b.payload = make([]byte, m.BlockSize)
+		if _, err := io.ReadFull(cr, b.payload); err != nil {
+			fail(fmt.Errorf("%w at offset %d: truncated block: %v", ErrHeader, m.Offset, err))
+			return
+		}
+		if err := readTrailer(cr, &b.member); err != nil {
+			fail(err)
+			return
+		}
+		if !send(order, b) || !send(jobs, b) {
+			return
+		}
+	}
+}
+
+// 解压普通 gzip 成员并写入 b.stream，边解压边计算 CRC32 与大小，读取尾部后校验；
+// 写出的一端在前面的块写出之后才开始读取。返回 false 时停止读取后续成员
+func streamMember(cr *countingReader, b *block, order chan<- *block, send func(chan<- *block, *block) bool, quit <-chan struct{}) bool {
+	pr, pw := io.Pipe()
+	b.stream = pr
+	close(b.done)
+	if !send(order, b) {
+		return false
+	}
+	// Decompress 提前返回时不再读取 stream
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-quit:
+			pr.CloseWithError(io.ErrClosedPipe)
+		case <-stopped:
+		}
+	}()
+
+	h := crc32.NewIEEE()
+	zr := flate.NewReader(cr)
+	n, err := io.Copy(io.MultiWriter(pw, h), zr)
+	zr.Close()
+	if err != nil {
+		err = fmt.Errorf("%w at offset %d: %v", ErrHeader, b.member.Offset, err)
+	} else if err = readTrailer(cr, &b.member); err == nil {
+		err = checkMember(b.member, h.Sum32(), n)
+	}
+	pw.CloseWithError(err)
+	return err == nil
+}
+
+// 解压一个 deflate 数据块，输出超过 size 时返回 ErrChecksum，不会完整展开压缩炸弹
+func inflate(payload []byte, size int) ([]byte, error) {
+	zr := flate.NewReader(bytes.NewReader(payload))
+	defer zr.Close()
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := io.Copy(buf, io.LimitReader(zr, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	if buf.Len() > size {
+		return nil, fmt.Errorf("%w: block inflates beyond its QZ chunk size %d", ErrChecksum, size)
+	}
+	return buf.Bytes(), nil
+}
+
+// 校验解压后的数据与成员记录的 CRC32、ISIZE 以及 QZ 字段中的大小
+func verify(m Member, data []byte) error {
+	return checkMember(m, crc32.ChecksumIEEE(data), int64(len(data)))
+}
+
+// 校验解压后数据的 CRC32 与大小
+func checkMember(m Member, crc uint32, size int64) error {
+	if crc != m.CRC32 {
+		return fmt.Errorf("%w at offset %d: crc32 %08x, want %08x", ErrChecksum, m.Offset, crc, m.CRC32)
+	}
+	// ISIZE 为原始大小对 2^32 取模
+	if uint32(size) != m.ISize {
+		return fmt.Errorf("%w at offset %d: size %d, want %d", ErrChecksum, m.Offset, size, m.ISize)
+	}
+	if m.QZ && size != int64(m.ChunkSize) {
+		return fmt.Errorf("%w at offset %d: size %d, QZ chunk size %d", ErrChecksum, m.Offset, size, m.ChunkSize)
+	}
+	return nil
+}
+
+// Reader is an io.ReadCloser that decompresses a gzip or gzipext stream, see Decompress.
+//
+// 并行解压的读取器
+type Reader struct {
+	pr *io.PipeReader
+}
+
+// NewReader returns a Reader that decompresses src with concurrency goroutines in the background.
+// Close must be called to release them when the stream is not read to the end.
+func NewReader(src io.Reader, concurrency int) *Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := Decompress(pw, src, concurrency)
+		pw.CloseWithError(err)
+	}()
+	return &Reader{pr: pr}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	return z.pr.Read(p)
+}
+
+// Close stops the decompression.
+func (z *Reader) Close() error {
+	return z.pr.Close()
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/crypt"
+)
+
+// Format is a file format recognized by Identify.
+//
+// 文件格式
+type Format int
+
+const (
+	// 无法识别的格式
+	FormatUnknown Format = iota
+	// 标准 gzip
+	FormatGzip
+	// 带有 QAT 扩展头（gzip FEXTRA 中的 "QZ" 子字段）的 gzip
+	FormatGzipExt
+	// LZ4 frame
+	FormatLz4
+	// QAT LZ4s，与 LZ4 frame 使用相同的 magic，只能通过 .lz4s 后缀区分
+	FormatLz4s
+	// 未压缩的 tar 归档
+	FormatTar
+	// 加密的数据（见 crypt 包），内容为压缩数据
+	FormatEncrypted
+)
+
+// ErrNotCompressed is returned, wrapped with the path and the detected format, when a file to
+// decompress is not in a compressed format.
+var ErrNotCompressed = errors.New("input is not compressed")
+
+// 识别格式需要读取的字节数：tar 头部的 ustar 位于偏移 257
+const identifySize = 512
+
+func (f Format) String() string {
+	switch f {
+	case FormatGzip:
+		return "gzip"
+	case FormatGzipExt:
+		return "gzipext"
+	case FormatLz4:
+		return "lz4"
+	case FormatLz4s:
+		return "lz4s"
+	case FormatTar:
+		return "tar"
+	case FormatEncrypted:
+		return "encrypted"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the format as its name, so that it reads well in JSON.
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// Compressed reports whether the format is one qzip can decompress.
+func (f Format) Compressed() bool {
+	return f == FormatGzip || f == FormatGzipExt || f == FormatLz4 || f == FormatLz4s
+}
+
+// Identify detects the format of the file at path from its magic bytes: gzip (1f 8b), the QAT
+// gzipext extra field, the LZ4 frame magic (04 22 4d 18), the tar "ustar" magic and the
+// encryption container magic ("QZGE").
+//
+// LZ4s streams carry the LZ4 frame magic, so an LZ4 frame is reported as FormatLz4s when path
+// ends with .lz4s.
+//
+// 根据 magic 识别文件格式
+func Identify(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer f.Close()
+	format, err := IdentifyReader(f)
+	if format == FormatLz4 && strings.HasSuffix(path, internal.SuffixLz4s) {
+		format = FormatLz4s
+	}
+	return format, err
+}
+
+// IdentifyReader detects the format of the data read from r, see Identify. It reads up to 512
+// bytes from r; wrap r in a bufio.Reader and use Peek to keep them. LZ4s cannot be told apart
+// from LZ4 without a file name and is reported as FormatLz4.
+//
+// 根据 magic 识别数据格式
+func IdentifyReader(r io.Reader) (Format, error) {
+	header := make([]byte, identifySize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return FormatUnknown, err
+	}
+	return identify(header[:n]), nil
+}
+
+// 根据文件开头的字节识别格式
+func identify(b []byte) Format {
+	switch {
+	case len(b) >= 3 && b[0] == 0x1f && b[1] == 0x8b && b[2] == 8:
+		if isGzipExt(b) {
+			return FormatGzipExt
+		}
+		return FormatGzip
+	case len(b) >= 4 && binary.LittleEndian.Uint32(b) == 0x184d2204:
+		return FormatLz4
+	case len(b) >= 262 && string(b[257:262]) == "ustar":
+		return FormatTar
+	case len(b) >= len(crypt.Magic) && string(b[:len(crypt.Magic)]) == crypt.Magic:
+		return FormatEncrypted
+	}
+	return FormatUnknown
+}
+
+// gzip 头部是否带有 QAT 扩展字段：FLG.FEXTRA、XLEN=12、SI1='Q'、SI2='Z'、LEN=8
+func isGzipExt(b []byte) bool {
+	if len(b) < 24 || b[3]&0x04 == 0 {
+		return false
+	}
+	return binary.LittleEndian.Uint16(b[10:]) == 12 && b[12] == 'Q' && b[13] == 'Z' &&
+		binary.LittleEndian.Uint16(b[14:]) == 8
+}
+
+// 检查文件是否为 gzip 压缩的 tar 归档
+func isCompressedTar(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return isCompressedTarReader(path, f)
+}
+
+// 检查 r 中的内容是否为 gzip 压缩的 tar 归档，path 只用于错误信息
+func isCompressedTarReader(path string, r io.Reader) error {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(identifySize)
+	if format := identify(header); format != FormatGzip && format != FormatGzipExt {
+		return fmt.Errorf("%s is not a gzip compressed tar archive (format %s)", path, format)
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+	format, err := IdentifyReader(zr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if format != FormatTar {
+		return fmt.Errorf("%s is not a gzip compressed tar archive (content %s)", path, format)
+	}
+	return nil
+}
+
+// 解压未指定算法与文件头时，根据输入文件的格式选择 -A/-O 选项
+//
+// gzip 与 gzipext 使用 qzip 的默认选项；不是压缩格式的输入返回 ErrNotCompressed。
+// 多个文件需要不同选项时返回 mixed，由调用方逐个文件处理
+func detectDecompression(cmd internal.QzipCommand) (internal.QzipCommand, bool, error) {
+	if cmd.Compression || cmd.Algorithm != 0 || cmd.FileHeader != 0 {
+		return cmd, false, nil
+	}
+	targets, err := internal.QzipTargets(cmd)
+	if err != nil {
+		return cmd, false, err
+	}
+	var formats []Format
+	for _, target := range targets {
+		format, err := Identify(target.Input)
+		if err != nil {
+			return cmd, false, err
+		}
+		if !format.Compressed() {
+			return cmd, false, fmt.Errorf("%w: %s (format %s)", ErrNotCompressed, target.Input, format)
+		}
+		if format == FormatGzipExt {
+			format = FormatGzip
+		}
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return cmd, false, nil
+	}
+	for _, format := range formats[1:] {
+		if format != formats[0] {
+			return cmd, true, nil
+		}
+	}
+	switch formats[0] {
+	case FormatLz4:
+		cmd.Algorithm, cmd.FileHeader = internal.LZ4, internal.FILE_HEADER_LZ4
+	case FormatLz4s:
+		cmd.Algorithm, cmd.FileHeader = internal.LZ4S, internal.FILE_HEADER_LZ4S
+	}
+	return cmd, false, nil
+}
@@ -0,0 +1,295 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// 增量压缩状态文件的版本
+const incrementalStateVersion = 1
+
+// IncrementalOptions configures CompressIncremental.
+//
+// 增量压缩选项
+type IncrementalOptions struct {
+	// 状态文件路径，记录每个已压缩文件的大小、修改时间、内容哈希以及输出文件；不存在时视为首次运行
+	StateFile string
+	// 输出目录，为空时压缩文件写在源文件旁边（此时会跳过已经压缩的文件），否则保持相对目录结构写入该目录
+	OutputDir string
+	// 选择文件的规则
+	Walk WalkOptions
+	// 为 true 时删除源文件已经不存在的压缩文件
+	RemoveStale bool
+}
+
+// 单个文件在状态文件中的记录
+type fileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+	Output  string    `json:"output"`
+}
+
+// 增量压缩状态，Files 的键为以 / 分隔的相对路径
+type incrementalState struct {
+	Version int                  `json:"version"`
+	Files   map[string]fileState `json:"files"`
+}
+
+// CompressIncremental compresses only the files of root that are new or changed since the previous run,
+// keeping the original files intact.
+//
+// A file is unchanged when its size and modification time match the state file, or when its content
+// hash still matches; unchanged files whose output still exists are listed in Report.Unchanged.
+// With RemoveStale, outputs whose sources have disappeared are removed and listed in Report.Removed.
+// The state file is only updated for files that were compressed successfully, and never in dry-run mode.
+// With Client.Manifest set, the compressed files are merged into the existing manifest after stale
+// outputs were removed, so the manifest keeps covering the files of earlier runs.
+//
+// 增量压缩：只压缩新增或发生变化的文件
+func (c *Client) CompressIncremental(root string, opts IncrementalOptions) (*Report, error) {
+	if opts.StateFile == "" {
+		return nil, errors.New("state file is empty")
+	}
+	if err := c.checkManifest(); err != nil {
+		return nil, err
+	}
+	state, err := loadIncrementalState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	walk := opts.Walk
+	if opts.OutputDir == "" {
+		// 压缩文件写在源文件旁边，不能再被当作源文件
+		walk.SkipCompressed = true
+	}
+	files, err := SelectFiles(root, walk)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	seen := make(map[string]bool, len(files))
+	pending := make(map[string]fileState)
+	var changed []string
+	// 状态文件与清单本身可能位于被压缩的目录中
+	excluded := make(map[string]bool)
+	stateFile, err := filepath.Abs(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	excluded[stateFile], excluded[stateFile+".tmp"] = true, true
+	if c.Manifest != nil {
+		manifestPath, err := filepath.Abs(c.Manifest.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, suffix := range []string{"", ".tmp", SignatureExtension} {
+			excluded[manifestPath+suffix] = true
+		}
+	}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil && excluded[abs] {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		current, unchanged, err := compareFileState(file, state.Files[rel])
+		if err != nil {
+			return nil, err
+		}
+		if unchanged {
+			state.Files[rel] = current
+			report.Unchanged = append(report.Unchanged, file)
+			continue
+		}
+		pending[file] = current
+		changed = append(changed, file)
+	}
+
+	if err := c.removeStale(report, root, state, seen, opts.RemoveStale); err != nil {
+		return nil, err
+	}
+
+	var runErr error
+	if len(changed) > 0 {
+		// 新的压缩文件替换上一次的压缩文件；压缩失败或被取消时上一次的压缩文件保持不变
+		overwrite := *c
+		overwrite.Collision = CollisionOverwrite
+		// 清单与签名在删除上一次的压缩文件之后统一写入
+		overwrite.Manifest, overwrite.Signature = nil, nil
+		if opts.OutputDir == "" {
+			var sub *Report
+			sub, runErr = overwrite.CompressFiles(changed...)
+			if sub != nil {
+				report.merge(sub)
+			}
+		} else {
+			runErr = overwrite.runTreeFiles(report, root, opts.OutputDir, changed, true)
+		}
+	}
+	if c.DryRun {
+		return report, runErr
+	}
+
+	// 只记录压缩成功的文件，失败的文件下次运行时重新压缩
+	for _, result := range report.Results {
+		current, ok := pending[result.Input]
+		if !ok {
+			continue
+		}
+		rel, _ := filepath.Rel(root, result.Input)
+		rel = filepath.ToSlash(rel)
+		// 压缩文件的路径发生变化时（如按日期命名）删除上一次的压缩文件
+		if previous := state.Files[rel]; previous.Output != "" && previous.Output != result.Output {
+			if err := os.Remove(previous.Output); err != nil && !os.IsNotExist(err) {
+				runErr = errors.Join(runErr, err)
+			}
+		}
+		current.Output = result.Output
+		state.Files[rel] = current
+	}
+	if err := saveIncrementalState(opts.StateFile, state); err != nil {
+		return report, errors.Join(runErr, err)
+	}
+	if err := c.finishCompress(report, false); err != nil {
+		return report, errors.Join(runErr, err)
+	}
+	return report, runErr
+}
+
+// CompressIncremental compresses the new or changed files of root with DefaultClient,
+// see Client.CompressIncremental.
+func CompressIncremental(root string, opts IncrementalOptions) (*Report, error) {
+	return DefaultClient.CompressIncremental(root, opts)
+}
+
+// 处理源文件已经不存在的记录：RemoveStale 时删除其压缩文件并移除记录
+func (c *Client) removeStale(report *Report, root string, state *incrementalState, seen map[string]bool, removeStale bool) error {
+	var stale []string
+	for rel, previous := range state.Files {
+		if seen[rel] {
+			continue
+		}
+		// 源文件仍然存在（例如过滤规则发生了变化）时保留记录
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+			continue
+		}
+		if !removeStale {
+			continue
+		}
+		if previous.Output != "" {
+			stale = append(stale, previous.Output)
+			if !c.DryRun {
+				if err := os.Remove(previous.Output); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		delete(state.Files, rel)
+	}
+	sort.Strings(stale)
+	report.Removed = append(report.Removed, stale...)
+	if c.DryRun && len(stale) > 0 {
+		report.Plans = append(report.Plans, Plan{Deletes: stale})
+	}
+	return nil
+}
+
+// 比较文件与上次记录的状态：大小与修改时间相同，或内容哈希相同，并且压缩文件仍然存在时视为未变化
+func compareFileState(path string, previous fileState) (fileState, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, false, err
+	}
+	current := fileState{Size: info.Size(), ModTime: info.ModTime(), SHA256: previous.SHA256, Output: previous.Output}
+	if previous.Output == "" || !internal.FileIsExist(previous.Output) {
+		current.SHA256, err = fileSHA256(path)
+		return current, false, err
+	}
+	if current.Size == previous.Size && current.ModTime.Equal(previous.ModTime) {
+		return current, true, nil
+	}
+	if current.SHA256, err = fileSHA256(path); err != nil {
+		return current, false, err
+	}
+	return current, current.SHA256 == previous.SHA256, nil
+}
+
+// 计算文件内容的 SHA-256
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 读取状态文件，不存在时返回空状态
+func loadIncrementalState(path string) (*incrementalState, error) {
+	state := &incrementalState{Version: incrementalStateVersion, Files: make(map[string]fileState)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if state.Version != incrementalStateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", state.Version)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]fileState)
+	}
+	return state, nil
+}
+
+// 写入状态文件：先写入临时文件再重命名，避免中断时损坏状态文件
+func saveIncrementalState(path string, state *incrementalState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件并 fsync，重命名后状态文件总是完整的
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncPath(filepath.Dir(path))
+}
@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/gzipext"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// Inspection describes the container of a compressed file, see Inspect.
+//
+// 压缩文件的结构信息
+type Inspection struct {
+	// 文件路径
+	Path string
+	// 文件格式
+	Format Format
+	// 文件大小
+	Size int64
+	// gzip/gzipext 的每个成员：原始文件名、修改时间、OS 字节、QZ 字段以及尾部的 CRC32 与 ISIZE
+	Members []gzipext.Member
+	// LZ4/LZ4s 的每个帧描述符
+	Frames []lz4s.FrameInfo
+	// LZ4/LZ4s 的每个数据块：偏移、压缩后的大小以及是否未压缩
+	Blocks []lz4s.BlockStats
+	// 未压缩的大小：gzip 为各成员 ISIZE 之和，LZ4 为各帧记录的内容大小之和；无法得知时为 -1
+	UncompressedSize int64
+}
+
+// Inspect reads the container structure of the compressed file at path without writing the
+// decompressed data: the members of a gzip or gzipext file (header fields, QZ block sizes, CRC32
+// and ISIZE trailers) or the frame descriptors and block sizes of an LZ4 or LZ4s file.
+//
+// gzipext blocks and LZ4 blocks are skipped by their recorded sizes; members without the QZ field
+// must be inflated to find their end. A file that is not compressed returns ErrNotCompressed.
+// When the structure is invalid, the error is returned together with what was read up to that point.
+//
+// 读取压缩文件的结构信息
+func Inspect(path string) (*Inspection, error) {
+	format, err := Identify(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	inspection := &Inspection{Path: path, Format: format, Size: info.Size(), UncompressedSize: -1}
+	switch format {
+	case FormatGzip, FormatGzipExt:
+		members, err := gzipext.Scan(f)
+		inspection.Members = members
+		if err != nil {
+			return inspection, fmt.Errorf("%s: %w", path, err)
+		}
+		inspection.UncompressedSize = 0
+		for _, m := range members {
+			inspection.UncompressedSize += int64(m.ISize)
+		}
+	case FormatLz4, FormatLz4s:
+		stats, err := lz4s.Scan(f)
+		inspection.Frames, inspection.Blocks = stats.Frames, stats.Blocks
+		if err != nil {
+			return inspection, fmt.Errorf("%s: %w", path, err)
+		}
+		inspection.UncompressedSize = lz4ContentSize(stats.Frames)
+	default:
+		return inspection, fmt.Errorf("%w: %s (format %s)", ErrNotCompressed, path, format)
+	}
+	return inspection, nil
+}
+
+// 各帧记录的内容大小之和，有帧没有记录时返回 -1
+func lz4ContentSize(frames []lz4s.FrameInfo) int64 {
+	var size int64
+	for _, frame := range frames {
+		if !frame.HasContentSize {
+			return -1
+		}
+		size += int64(frame.ContentSize)
+	}
+	return size
+}
@@ -0,0 +1,158 @@
+package lz4s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MinMatch is added to the match length code of an LZ4s sequence. Unlike LZ4 (minimum match 4),
+// a match length code of 0 means the sequence has no match.
+const MinMatch = 2
+
+// ErrCorrupt is returned when a block or frame is malformed.
+var ErrCorrupt = errors.New("lz4s: corrupt data")
+
+// BlockStats describes the sequences of one block.
+//
+// 单个数据块的统计信息
+type BlockStats struct {
+	// 所属帧的序号，从 0 开始
+	Frame int
+	// 块（包括 4 字节的块大小）在流中的偏移
+	Offset int64
+	// 块数据的大小
+	CompressedSize int
+	// 解码后的大小
+	UncompressedSize int
+	// 是否为未压缩的块
+	Stored bool
+	// 序列数
+	Sequences int
+	// 只有字面量、没有匹配的序列数（包括块末尾的序列）
+	LiteralOnly int
+	// 字面量字节数
+	LiteralBytes int
+	// 匹配数
+	Matches int
+	// 匹配复制的字节数
+	MatchBytes int
+	// 最大的匹配偏移
+	MaxOffset int
+}
+
+// DecodeBlock decodes the LZ4s block src and appends the result to dst, which may hold the
+// previous output that matches refer to. It returns the extended slice.
+//
+// Every sequence starts with a token: the high nibble is the literal length, the low nibble the
+// match length code, 15 meaning that extension bytes follow (each adds its value, 255 continues).
+// The literals are followed by a 2-byte little endian offset, except for the last sequence of the
+// block, and by the match length extension bytes. A match copies code+MinMatch bytes from offset
+// bytes back; a code of 0 means no match. A block decoding to more than the largest LZ4 block
+// (4 MiB) is rejected with ErrCorrupt.
+//
+// 解码一个 LZ4s 数据块
+func DecodeBlock(dst, src []byte) ([]byte, error) {
+	dst, _, err := decodeBlock(dst, src, 4<<20, nil)
+	return dst, err
+}
+
+// 解码数据块并统计序列信息，stats 可以为 nil；返回解码结果与块内出错的位置
+//
+// 解码结果超过 limit 字节时立即返回 ErrCorrupt，不会先展开整个块
+func decodeBlock(dst, src []byte, limit int, stats *BlockStats) ([]byte, int, error) {
+	start := len(dst)
+	ip := 0
+	for ip < len(src) {
+		token := src[ip]
+		ip++
+		literals := int(token >> 4)
+		if literals == 15 {
+			n, next, err := readLength(src, ip)
+			if err != nil {
+				return dst, ip, err
+			}
+			literals += n
+			ip = next
+		}
+		if literals > len(src)-ip {
+			return dst, ip, fmt.Errorf("%w: literal length %d exceeds block", ErrCorrupt, literals)
+		}
+		if len(dst)-start+literals > limit {
+			return dst, ip, fmt.Errorf("%w: block decodes to more than %d bytes", ErrCorrupt, limit)
+		}
+		dst = append(dst, src[ip:ip+literals]...)
+		ip += literals
+		if stats != nil {
+			stats.Sequences++
+			stats.LiteralBytes += literals
+		}
+		// 块末尾的序列只有字面量
+		if ip == len(src) {
+			if stats != nil {
+				stats.LiteralOnly++
+			}
+			break
+		}
+		if len(src)-ip < 2 {
+			return dst, ip, fmt.Errorf("%w: truncated offset", ErrCorrupt)
+		}
+		offset := int(binary.LittleEndian.Uint16(src[ip:]))
+		ip += 2
+		code := int(token & 15)
+		if code == 15 {
+			n, next, err := readLength(src, ip)
+			if err != nil {
+				return dst, ip, err
+			}
+			code += n
+			ip = next
+		}
+		if code == 0 {
+			if stats != nil {
+				stats.LiteralOnly++
+			}
+			continue
+		}
+		length := code + MinMatch
+		if offset == 0 || offset > len(dst) {
+			return dst, ip, fmt.Errorf("%w: match offset %d out of range", ErrCorrupt, offset)
+		}
+		if len(dst)-start+length > limit {
+			return dst, ip, fmt.Errorf("%w: block decodes to more than %d bytes", ErrCorrupt, limit)
+		}
+		// 匹配可能与输出重叠：输出以 offset 为周期重复，每次复制 from 之后已有的全部数据，长度按倍数增长
+		from := len(dst) - offset
+		for copied := 0; copied < length; {
+			n := min(length-copied, len(dst)-from)
+			dst = append(dst, dst[from:from+n]...)
+			copied += n
+		}
+		if stats != nil {
+			stats.Matches++
+			stats.MatchBytes += length
+			stats.MaxOffset = max(stats.MaxOffset, offset)
+		}
+	}
+	if stats != nil {
+		stats.CompressedSize = len(src)
+		stats.UncompressedSize = len(dst) - start
+	}
+	return dst, ip, nil
+}
+
+// 读取长度扩展字节：每个字节累加，255 表示继续
+func readLength(src []byte, ip int) (int, int, error) {
+	n := 0
+	for {
+		if ip >= len(src) {
+			return 0, ip, fmt.Errorf("%w: truncated length", ErrCorrupt)
+		}
+		b := src[ip]
+		ip++
+		n += int(b)
+		if b != 255 {
+			return n, ip, nil
+		}
+	}
+}
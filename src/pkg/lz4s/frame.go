@@ -0,0 +1,358 @@
+// Package lz4s decodes the LZ4s streams written by qzip with the LZ4s format (-A lz4s -O lz4s)
+// without QAT hardware, validates their structure and reports block statistics.
+//
+// LZ4s data is carried in standard LZ4 frames (magic 04 22 4d 18, frame descriptor, size-prefixed
+// blocks, optional XXH32 checksums), but the compressed blocks use the QAT LZ4s sequence format,
+// see DecodeBlock, which ordinary LZ4 tools cannot read.
+//
+// LZ4s 格式的纯 Go 解码器
+package lz4s
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// 帧 magic
+const (
+	frameMagic     = 0x184d2204
+	skippableMagic = 0x184d2a50
+	skippableMask  = 0xfffffff0
+)
+
+// 匹配可以引用的历史数据长度
+const windowSize = 64 << 10
+
+// 单个数据块大小的上限，避免损坏的块大小导致过大的内存分配
+const maxBlockSize = 8 << 20
+
+// FrameInfo describes the descriptor of one LZ4 frame.
+//
+// 帧描述符
+type FrameInfo struct {
+	// 帧在流中的偏移
+	Offset int64
+	// 块之间是否独立（B.Indep）
+	BlockIndependent bool
+	// 每个块是否带有校验和（B.Checksum）
+	BlockChecksum bool
+	// 帧末尾是否带有内容校验和（C.Checksum）
+	ContentChecksum bool
+	// 是否记录了内容大小（C.Size）
+	HasContentSize bool
+	// 内容大小
+	ContentSize uint64
+	// 是否带有字典 ID
+	HasDictID bool
+	// 字典 ID
+	DictID uint32
+	// 块的最大大小（BD）
+	BlockMaxSize int
+	// 块数
+	Blocks int
+}
+
+// Stats describes a whole stream.
+//
+// 整个数据流的统计信息
+type Stats struct {
+	// 每个帧的描述符；出错时最后一个为出错的帧，其描述符可能不完整
+	Frames []FrameInfo
+	// 每个数据块的统计信息
+	Blocks []BlockStats
+	// 数据流的字节数
+	CompressedSize int64
+	// 解码后的字节数，Scan 时为 0
+	UncompressedSize int64
+}
+
+// 记录偏移的读取器
+type countingReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+// 帧与数据块的解析器，out 为 nil 时只读取结构，不解码数据块
+type decoder struct {
+	r       *countingReader
+	out     io.Writer
+	decode  bool
+	stats   *Stats
+	written int64
+}
+
+// Decompress decodes the LZ4s stream src, which may hold several frames, into dst and returns
+// the number of bytes written. Header, block and content checksums are verified when present.
+//
+// 解码 LZ4s 数据流
+func Decompress(dst io.Writer, src io.Reader) (int64, error) {
+	d := newDecoder(src, dst, true)
+	err := d.run()
+	return d.written, err
+}
+
+// Inspect decodes the LZ4s stream src, discarding the output, and returns the frame descriptors
+// and the statistics of every block. An error tells where the structure is invalid; the
+// statistics gathered up to that point are returned with it.
+//
+// 校验 LZ4s 数据流并统计每个数据块
+func Inspect(src io.Reader) (*Stats, error) {
+	d := newDecoder(src, io.Discard, true)
+	err := d.run()
+	return d.stats, err
+}
+
+// Scan reads the frame descriptors and block sizes of an LZ4 or LZ4s stream without decoding
+// the blocks, so it also works for ordinary LZ4 frames. Only the offset, the compressed size and
+// the stored flag of each block are set (and the uncompressed size of stored blocks); header and
+// block checksums are verified.
+//
+// 只读取帧描述符与块大小
+func Scan(src io.Reader) (*Stats, error) {
+	d := newDecoder(src, nil, false)
+	err := d.run()
+	return d.stats, err
+}
+
+func newDecoder(src io.Reader, out io.Writer, decode bool) *decoder {
+	return &decoder{
+		r:      &countingReader{r: bufio.NewReaderSize(src, 1<<16)},
+		out:    out,
+		decode: decode,
+		stats:  &Stats{},
+	}
+}
+
+// 依次读取每个帧，直到数据流结束
+func (d *decoder) run() error {
+	defer func() {
+		d.stats.CompressedSize = d.r.offset
+		d.stats.UncompressedSize = d.written
+	}()
+	for {
+		offset := d.r.offset
+		var buf [4]byte
+		n, err := io.ReadFull(d.r, buf[:])
+		if err == io.EOF && len(d.stats.Frames) > 0 {
+			return nil
+		} else if err != nil {
+			d.stats.Frames = append(d.stats.Frames, FrameInfo{Offset: offset})
+			return fmt.Errorf("%w at offset %d: truncated frame magic (%d bytes)", ErrCorrupt, offset, n)
+		}
+		magic := binary.LittleEndian.Uint32(buf[:])
+		if magic&skippableMask == skippableMagic {
+			if err := d.skipFrame(offset); err != nil {
+				return err
+			}
+			continue
+		}
+		if magic != frameMagic {
+			d.stats.Frames = append(d.stats.Frames, FrameInfo{Offset: offset})
+			return fmt.Errorf("%w at offset %d: bad frame magic %08x", ErrCorrupt, offset, magic)
+		}
+		if err := d.frame(offset); err != nil {
+			return err
+		}
+	}
+}
+
+// 跳过 skippable 帧
+func (d *decoder) skipFrame(offset int64) error {
+	var buf [4]byte
+	if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+		return fmt.Errorf("%w at offset %d: truncated skippable frame", ErrCorrupt, offset)
+	}
+	size := int64(binary.LittleEndian.Uint32(buf[:]))
+	if _, err := io.CopyN(io.Discard, d.r, size); err != nil {
+		return fmt.Errorf("%w at offset %d: truncated skippable frame", ErrCorrupt, offset)
+	}
+	return nil
+}
+
+// 读取帧描述符
+func (d *decoder) header(offset int64) (FrameInfo, error) {
+	info := FrameInfo{Offset: offset}
+	descriptor := make([]byte, 2, 14)
+	if _, err := io.ReadFull(d.r, descriptor); err != nil {
+		return info, fmt.Errorf("%w at offset %d: truncated frame descriptor", ErrCorrupt, offset)
+	}
+	flg, bd := descriptor[0], descriptor[1]
+	if flg>>6 != 1 {
+		return info, fmt.Errorf("%w at offset %d: unsupported frame version %d", ErrCorrupt, offset, flg>>6)
+	}
+	if flg&0x02 != 0 || bd&0x8f != 0 {
+		return info, fmt.Errorf("%w at offset %d: reserved descriptor bits set", ErrCorrupt, offset)
+	}
+	info.BlockIndependent = flg&0x20 != 0
+	info.BlockChecksum = flg&0x10 != 0
+	info.HasContentSize = flg&0x08 != 0
+	info.ContentChecksum = flg&0x04 != 0
+	info.HasDictID = flg&0x01 != 0
+	switch bd >> 4 {
+	case 4:
+		info.BlockMaxSize = 64 << 10
+	case 5:
+		info.BlockMaxSize = 256 << 10
+	case 6:
+		info.BlockMaxSize = 1 << 20
+	case 7:
+		info.BlockMaxSize = 4 << 20
+	default:
+		return info, fmt.Errorf("%w at offset %d: invalid block maximum size %d", ErrCorrupt, offset, bd>>4)
+	}
+	extra := 0
+	if info.HasContentSize {
+		extra += 8
+	}
+	if info.HasDictID {
+		extra += 4
+	}
+	descriptor = descriptor[:2+extra+1]
+	if _, err := io.ReadFull(d.r, descriptor[2:]); err != nil {
+		return info, fmt.Errorf("%w at offset %d: truncated frame descriptor", ErrCorrupt, offset)
+	}
+	rest := descriptor[2:]
+	if info.HasContentSize {
+		info.ContentSize = binary.LittleEndian.Uint64(rest)
+		rest = rest[8:]
+	}
+	if info.HasDictID {
+		info.DictID = binary.LittleEndian.Uint32(rest)
+	}
+	hc := descriptor[len(descriptor)-1]
+	if want := byte(Checksum(descriptor[:len(descriptor)-1]) >> 8); hc != want {
+		return info, fmt.Errorf("%w at offset %d: header checksum %02x, want %02x", ErrCorrupt, offset, hc, want)
+	}
+	return info, nil
+}
+
+// 读取并解码一个帧的全部数据块
+func (d *decoder) frame(offset int64) error {
+	info, err := d.header(offset)
+	d.stats.Frames = append(d.stats.Frames, info)
+	if err != nil {
+		return err
+	}
+	frame := &d.stats.Frames[len(d.stats.Frames)-1]
+	var content digest
+	var history []byte
+	var size uint64
+	for {
+		blockOffset := d.r.offset
+		var buf [4]byte
+		if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+			return fmt.Errorf("%w at offset %d: truncated block size", ErrCorrupt, blockOffset)
+		}
+		raw := binary.LittleEndian.Uint32(buf[:])
+		if raw == 0 {
+			break
+		}
+		stored := raw&0x80000000 != 0
+		n := int(raw & 0x7fffffff)
+		if n > maxBlockSize || (stored && n > info.BlockMaxSize) {
+			return fmt.Errorf("%w at offset %d: block size %d exceeds the maximum", ErrCorrupt, blockOffset, n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return fmt.Errorf("%w at offset %d: truncated block", ErrCorrupt, blockOffset)
+		}
+		if info.BlockChecksum {
+			if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+				return fmt.Errorf("%w at offset %d: truncated block checksum", ErrCorrupt, blockOffset)
+			}
+			if got, want := Checksum(data), binary.LittleEndian.Uint32(buf[:]); got != want {
+				return fmt.Errorf("%w at offset %d: block checksum %08x, want %08x", ErrCorrupt, blockOffset, got, want)
+			}
+		}
+		frame.Blocks++
+		stats := BlockStats{Frame: len(d.stats.Frames) - 1, Offset: blockOffset, Stored: stored}
+		if !d.decode {
+			stats.CompressedSize = n
+			if stored {
+				stats.UncompressedSize = n
+			}
+			d.stats.Blocks = append(d.stats.Blocks, stats)
+			continue
+		}
+
+		var output []byte
+		if stored {
+			output = data
+			stats.CompressedSize, stats.UncompressedSize = n, n
+		} else {
+			dst, pos, err := decodeBlock(history, data, info.BlockMaxSize, &stats)
+			if err != nil {
+				return fmt.Errorf("%w (block at offset %d, byte %d)", err, blockOffset, pos)
+			}
+			output = dst[len(history):]
+		}
+		if stats.UncompressedSize > info.BlockMaxSize {
+			return fmt.Errorf("%w at offset %d: block decodes to %d bytes, maximum %d", ErrCorrupt, blockOffset, stats.UncompressedSize, info.BlockMaxSize)
+		}
+		d.stats.Blocks = append(d.stats.Blocks, stats)
+		if info.BlockIndependent {
+			history = history[:0]
+		} else {
+			// 保留最近 64KB 供后续块的匹配引用
+			history = append(history, output...)
+			if len(history) > windowSize {
+				history = append(history[:0], history[len(history)-windowSize:]...)
+			}
+		}
+		content.Write(output)
+		size += uint64(len(output))
+		w, err := d.out.Write(output)
+		d.written += int64(w)
+		if err != nil {
+			return err
+		}
+	}
+	if info.ContentChecksum {
+		var buf [4]byte
+		if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+			return fmt.Errorf("%w at offset %d: truncated content checksum", ErrCorrupt, d.r.offset)
+		}
+		if want := binary.LittleEndian.Uint32(buf[:]); d.decode && content.Sum32() != want {
+			return fmt.Errorf("%w in frame at offset %d: content checksum %08x, want %08x", ErrCorrupt, offset, content.Sum32(), want)
+		}
+	}
+	if d.decode && info.HasContentSize && size != info.ContentSize {
+		return fmt.Errorf("%w in frame at offset %d: content size %d, want %d", ErrCorrupt, offset, size, info.ContentSize)
+	}
+	return nil
+}
+
+// Reader is an io.ReadCloser that decodes an LZ4s stream, see Decompress.
+//
+// LZ4s 解码读取器
+type Reader struct {
+	pr *io.PipeReader
+}
+
+// NewReader returns a Reader that decodes src in the background. Close must be called to stop it
+// when the stream is not read to the end.
+func NewReader(src io.Reader) *Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := Decompress(pw, src)
+		pw.CloseWithError(err)
+	}()
+	return &Reader{pr: pr}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	return z.pr.Read(p)
+}
+
+// Close stops the decoding.
+func (z *Reader) Close() error {
+	return z.pr.Close()
+}
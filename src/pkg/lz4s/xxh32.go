@@ -0,0 +1,95 @@
+package lz4s
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH32 常量
+const (
+	prime1 uint32 = 2654435761
+	prime2 uint32 = 2246822519
+	prime3 uint32 = 3266489917
+	prime4 uint32 = 668265263
+	prime5 uint32 = 374761393
+)
+
+// Checksum returns the XXH32 hash (seed 0) used by the LZ4 frame format for the header,
+// block and content checksums.
+func Checksum(data []byte) uint32 {
+	var d digest
+	d.Write(data)
+	return d.Sum32()
+}
+
+// 流式 XXH32（seed 0）
+type digest struct {
+	v     [4]uint32
+	buf   [16]byte
+	n     int
+	total uint64
+	init  bool
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	if !d.init {
+		// 常量运算会溢出，使用变量按 uint32 回绕
+		p1 := prime1
+		d.v = [4]uint32{p1 + prime2, prime2, 0, 0 - p1}
+		d.init = true
+	}
+	n := len(p)
+	d.total += uint64(n)
+	if d.n > 0 {
+		c := copy(d.buf[d.n:], p)
+		d.n += c
+		p = p[c:]
+		if d.n < 16 {
+			return n, nil
+		}
+		d.stripe(d.buf[:])
+		d.n = 0
+	}
+	for len(p) >= 16 {
+		d.stripe(p[:16])
+		p = p[16:]
+	}
+	d.n = copy(d.buf[:], p)
+	return n, nil
+}
+
+func (d *digest) stripe(b []byte) {
+	for i := range d.v {
+		d.v[i] = round(d.v[i], binary.LittleEndian.Uint32(b[4*i:]))
+	}
+}
+
+func (d *digest) Sum32() uint32 {
+	var h uint32
+	if d.total >= 16 {
+		h = bits.RotateLeft32(d.v[0], 1) + bits.RotateLeft32(d.v[1], 7) +
+			bits.RotateLeft32(d.v[2], 12) + bits.RotateLeft32(d.v[3], 18)
+	} else {
+		h = prime5
+	}
+	h += uint32(d.total)
+	p := d.buf[:d.n]
+	for ; len(p) >= 4; p = p[4:] {
+		h += binary.LittleEndian.Uint32(p) * prime3
+		h = bits.RotateLeft32(h, 17) * prime4
+	}
+	for _, b := range p {
+		h += uint32(b) * prime5
+		h = bits.RotateLeft32(h, 11) * prime1
+	}
+	h ^= h >> 15
+	h *= prime2
+	h ^= h >> 13
+	h *= prime3
+	h ^= h >> 16
+	return h
+}
+
+func round(v, input uint32) uint32 {
+	return bits.RotateLeft32(v+input*prime2, 13) * prime1
+}
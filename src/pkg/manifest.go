@@ -0,0 +1,411 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestFormat is the file format of a checksum manifest.
+//
+// 校验清单格式
+type ManifestFormat int
+
+const (
+	// JSON 格式，记录每个文件的角色、大小与 SHA-256
+	ManifestJSON ManifestFormat = iota
+	// sha256sum 格式，可以直接使用 sha256sum -c 校验
+	ManifestSHA256Sum
+)
+
+// 清单文件的版本
+const manifestVersion = 1
+
+// 清单中文件的角色
+const (
+	// 原始文件，tar 归档中为条目名称
+	RoleSource = "source"
+	// 压缩后的文件或归档
+	RoleCompressed = "compressed"
+)
+
+// ErrManifestMismatch is returned, wrapped with the expected and actual checksums, for a file
+// whose content does not match its manifest entry.
+var ErrManifestMismatch = errors.New("checksum does not match the manifest")
+
+// ManifestOptions configures the checksum manifest written by compression operations.
+//
+// 校验清单选项
+type ManifestOptions struct {
+	// 清单文件路径；为空时 tar 归档的清单写在归档旁边（归档路径加上 .manifest.json 或 .sha256），
+	// 其他操作必须设置
+	Path string
+	// 清单格式
+	Format ManifestFormat
+}
+
+// Manifest lists the SHA-256 checksums of the original and compressed files of an operation.
+//
+// Paths use forward slashes and are relative to the directory of the manifest file, except the
+// sources of a tar archive, which are entry names: relative to the directory the archive is
+// extracted into.
+//
+// 校验清单
+type Manifest struct {
+	Version int             `json:"version"`
+	Created time.Time       `json:"created"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one file of a Manifest.
+//
+// 清单中的一个文件
+type ManifestEntry struct {
+	// 文件路径
+	Path string `json:"path"`
+	// RoleSource 或 RoleCompressed，sha256sum 格式中没有记录
+	Role string `json:"role,omitempty"`
+	// 文件大小，sha256sum 格式中没有记录时为 -1
+	Size int64 `json:"size"`
+	// 十六进制的 SHA-256
+	SHA256 string `json:"sha256"`
+}
+
+// 清单格式对应的默认后缀
+func (f ManifestFormat) extension() string {
+	if f == ManifestSHA256Sum {
+		return ".sha256"
+	}
+	return ".manifest.json"
+}
+
+// 检查清单选项：不是 tar 归档的操作必须设置清单路径
+func (c *Client) checkManifest() error {
+	if c.Manifest != nil && c.Manifest.Path == "" {
+		return errors.New("manifest path is empty")
+	}
+	return nil
+}
+
+// 为逐个文件压缩的结果写入清单：每个源文件及其输出，跳过的文件不记录；返回清单路径，没有写入时为空
+//
+// 清单已经存在时与其合并：本次压缩的文件替换原有的记录，其他仍然存在的文件保留原有的记录，
+// 多次压缩（如增量压缩）写入同一个清单时不会丢失之前的文件
+func (c *Client) writeFilesManifest(report *Report) (string, error) {
+	if c.Manifest == nil || c.DryRun {
+		return "", nil
+	}
+	dir := filepath.Dir(c.Manifest.Path)
+	var sources, outputs []ManifestEntry
+	for _, result := range report.Results {
+		if result.Decision == DecisionSkipped || result.Output == "" {
+			continue
+		}
+		source, err := manifestEntry(dir, result.Input, RoleSource)
+		if err != nil {
+			return "", err
+		}
+		output, err := manifestEntry(dir, result.Output, RoleCompressed)
+		if err != nil {
+			return "", err
+		}
+		sources, outputs = append(sources, source), append(outputs, output)
+	}
+	files := append(sources, outputs...)
+	previous, err := previousManifestEntries(c.Manifest.Path, files)
+	if err != nil {
+		return "", err
+	}
+	return c.Manifest.Path, writeManifest(c.Manifest.Path, c.Manifest.Format, append(previous, files...))
+}
+
+// 读取已有清单中需要保留的记录：不在 files 中且文件仍然存在；清单不存在时返回空
+func previousManifestEntries(manifestPath string, files []ManifestEntry) ([]ManifestEntry, error) {
+	manifest, err := ReadManifest(manifestPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]bool, len(files))
+	for _, file := range files {
+		replaced[file.Path] = true
+	}
+	dir := filepath.Dir(manifestPath)
+	var kept []ManifestEntry
+	for _, entry := range manifest.Files {
+		if replaced[entry.Path] {
+			continue
+		}
+		// 已经被删除的文件（如增量压缩删除的旧压缩文件）不再记录
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(entry.Path))); err != nil {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept, nil
+}
+
+// 为 tar 归档写入清单：从写好的归档中读取每个文件条目（以条目名称记录）以及归档本身；返回清单路径，没有写入时为空
+//
+// 条目的校验和来自归档而不是源目录，压缩期间或之后被修改的源文件不会使清单与归档不一致
+func (c *Client) writeTarManifest(report *Report) (string, error) {
+	if c.Manifest == nil || c.DryRun || len(report.Results) == 0 {
+		return "", nil
+	}
+	result := report.Results[len(report.Results)-1]
+	if result.Decision == DecisionSkipped {
+		return "", nil
+	}
+	manifestPath := c.Manifest.Path
+	if manifestPath == "" {
+		manifestPath = result.Output + c.Manifest.Format.extension()
+	}
+	files, err := c.archiveEntries(result.Output)
+	if err != nil {
+		return "", err
+	}
+	archive, err := manifestEntry(filepath.Dir(manifestPath), result.Output, RoleCompressed)
+	if err != nil {
+		return "", err
+	}
+	return manifestPath, writeManifest(manifestPath, c.Manifest.Format, append(files, archive))
+}
+
+// 计算 tar 归档中每个普通文件条目的大小与 SHA-256，硬链接使用其目标的校验和
+func (c *Client) archiveEntries(archive string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	index := make(map[string]int)
+	err := c.readArchive(archive, func(hdr *tar.Header, r io.Reader) error {
+		name := path.Clean(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			h := sha256.New()
+			n, err := io.Copy(h, r)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", archive, hdr.Name, err)
+			}
+			index[name] = len(entries)
+			entries = append(entries, ManifestEntry{Path: name, Role: RoleSource, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+		case tar.TypeLink:
+			if i, ok := index[path.Clean(hdr.Linkname)]; ok {
+				entry := entries[i]
+				entry.Path = name
+				index[name] = len(entries)
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	// 按名称排序，清单不依赖 tar 读取目录的顺序
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, err
+}
+
+// 计算文件的大小与 SHA-256，路径记录为相对于 dir 的路径
+func manifestEntry(dir, file, role string) (ManifestEntry, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	sum, err := fileSHA256(file)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{Path: relativePath(dir, file), Role: role, Size: info.Size(), SHA256: sum}, nil
+}
+
+// 相对于 dir 的路径，无法计算时使用绝对路径
+func relativePath(dir, file string) string {
+	if absDir, err := filepath.Abs(dir); err == nil {
+		if absFile, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(absDir, absFile); err == nil {
+				return filepath.ToSlash(rel)
+			}
+			return filepath.ToSlash(absFile)
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// 写入清单文件：先写入临时文件再重命名
+func writeManifest(manifestPath string, format ManifestFormat, files []ManifestEntry) error {
+	var buf bytes.Buffer
+	if format == ManifestSHA256Sum {
+		for _, file := range files {
+			fmt.Fprintf(&buf, "%s  %s\n", file.SHA256, file.Path)
+		}
+	} else {
+		manifest := Manifest{Version: manifestVersion, Created: time.Now().UTC(), Files: files}
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	tmp := manifestPath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, manifestPath)
+}
+
+// ReadManifest reads a manifest in JSON or sha256sum format; the format is detected from the content.
+//
+// 读取校验清单
+func ReadManifest(manifestPath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		manifest := &Manifest{}
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", manifestPath, err)
+		}
+		if manifest.Version != manifestVersion {
+			return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+		}
+		return manifest, nil
+	}
+	manifest := &Manifest{Version: manifestVersion}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		// sha256sum 以 "  " 分隔文本模式，以 " *" 分隔二进制模式
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != sha256.Size*2 || len(name) < 2 || (name[0] != ' ' && name[0] != '*') {
+			return nil, fmt.Errorf("invalid manifest %s: line %d", manifestPath, n)
+		}
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: line %d", manifestPath, n)
+		}
+		manifest.Files = append(manifest.Files, ManifestEntry{Path: name[1:], Size: -1, SHA256: strings.ToLower(sum)})
+	}
+	return manifest, scanner.Err()
+}
+
+// VerifyManifest re-checks the files listed in the manifest at manifestPath.
+//
+// target selects what is checked: a directory (the directory of the manifest when empty), in which
+// every listed path is looked up, or a compressed tar archive, whose entries are read and compared
+// with the listed sources while the archive itself is compared with its own entry.
+//
+// Files that match are listed in Report.Succeeded; mismatches (ErrManifestMismatch) and missing
+// files are listed in Report.Failed, and the error joins them. With Client.Signature.PublicKey set,
+// the signature of the manifest is verified first.
+//
+// 根据校验清单校验目录或 tar 归档
+func (c *Client) VerifyManifest(manifestPath, target string) (*Report, error) {
+	if err := c.verifySignature(manifestPath); err != nil {
+		return nil, err
+	}
+	manifest, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		target = filepath.Dir(manifestPath)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if info.IsDir() {
+		for _, entry := range manifest.Files {
+			report.check(entry, func() (int64, string, error) {
+				return fileChecksum(filepath.Join(target, filepath.FromSlash(entry.Path)))
+			})
+		}
+	} else if err := c.verifyArchiveManifest(report, manifest, manifestPath, target); err != nil {
+		return report, err
+	}
+	return report, report.failure()
+}
+
+// VerifyManifest re-checks a directory or a tar archive against its manifest with DefaultClient,
+// see Client.VerifyManifest.
+func VerifyManifest(manifestPath, target string) (*Report, error) {
+	return DefaultClient.VerifyManifest(manifestPath, target)
+}
+
+// 读取 tar 归档的全部条目并与清单比较；清单中指向归档本身的文件与归档的校验和比较
+func (c *Client) verifyArchiveManifest(report *Report, manifest *Manifest, manifestPath, archive string) error {
+	archiveInfo, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+	files, err := c.archiveEntries(archive)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]ManifestEntry, len(files))
+	for _, file := range files {
+		entries[file.Path] = file
+	}
+
+	dir := filepath.Dir(manifestPath)
+	for _, entry := range manifest.Files {
+		if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(entry.Path))); err == nil && os.SameFile(info, archiveInfo) {
+			report.check(entry, func() (int64, string, error) { return fileChecksum(archive) })
+			continue
+		}
+		report.check(entry, func() (int64, string, error) {
+			s, ok := entries[path.Clean(entry.Path)]
+			if !ok {
+				return 0, "", fmt.Errorf("entry not found in %s: %w", archive, fs.ErrNotExist)
+			}
+			return s.Size, s.SHA256, nil
+		})
+	}
+	return nil
+}
+
+// 计算文件的大小与 SHA-256
+func fileChecksum(file string) (int64, string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, "", err
+	}
+	sum, err := fileSHA256(file)
+	return info.Size(), sum, err
+}
+
+// 比较一个文件与清单中的记录，结果记录在 Succeeded 或 Failed 中
+func (r *Report) check(entry ManifestEntry, checksum func() (int64, string, error)) {
+	size, sum, err := checksum()
+	if err == nil && (sum != entry.SHA256 || (entry.Size >= 0 && size != entry.Size)) {
+		err = fmt.Errorf("%w: sha256 %s (%d bytes), want %s (%d bytes)", ErrManifestMismatch, sum, size, entry.SHA256, entry.Size)
+	}
+	if err != nil {
+		r.addFailure(entry.Path, err)
+		return
+	}
+	r.Succeeded = append(r.Succeeded, entry.Path)
+}
+
+// 合并全部失败文件的错误
+func (r *Report) failure() error {
+	var errs []error
+	for _, file := range r.failedOrder {
+		errs = append(errs, fmt.Errorf("%s: %w", file, r.Failed[file]))
+	}
+	return errors.Join(errs...)
+}
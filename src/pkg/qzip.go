@@ -1,8 +1,7 @@
 package pkg
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,27 +21,33 @@ const (
 )
 
 // 检查是否qat是否可用
-func Available() bool {
+func (c *Client) Available() bool {
+	QatService.Exec = c.Exec
+	QatService.Logger = c.Logger
+	logger := c.logger()
 	envAvailable := internal.CheckQATEnv(QatService)
 	hwAvailable := internal.CheckQATHWState(QatService)
 	QatService.TarIsAvailable = internal.CheckTarIsAvailable(QatService)
 	QatService.QzipIsAvailable = internal.CheckQzipIsAvailable(QatService)
-	if envAvailable && hwAvailable && hwIsAvailable(QatService.Hardwareses) && QatService.TarIsAvailable && QatService.QzipIsAvailable && RunCompressTest() && RunDecompressTest() {
-		log.Println("\033[1;32m ****** QAT服务可用 ******\033[0m")
+	if envAvailable && hwAvailable && hwIsAvailable(logger, QatService.Hardwareses) && QatService.TarIsAvailable && QatService.QzipIsAvailable && c.RunCompressTest() && c.RunDecompressTest() {
+		logger.Info("QAT服务可用", "service", QatService.String())
 	} else {
-		log.Print("\033[1;31m ****** QAT服务不可用 ****** \033[0m")
+		logger.Error("QAT服务不可用")
 		return false
 	}
-
-	fmt.Print(QatService.String())
 	return true
 }
 
-func hwIsAvailable(hws []*internal.Hardwarese) bool {
+// Available checks the QAT environment with DefaultClient, see Client.Available.
+func Available() bool {
+	return DefaultClient.Available()
+}
+
+func hwIsAvailable(logger *slog.Logger, hws []*internal.Hardwarese) bool {
 	var availableCount int = 0
 	for _, hw := range hws {
 		if hw.State == nil || *hw.State == "down" {
-			log.Println("设备", *hw.Name, "不可用")
+			logger.Warn("设备不可用", "device", *hw.Name)
 			continue
 		} else if *hw.State == "up" {
 			availableCount = availableCount + 1
@@ -52,32 +57,33 @@ func hwIsAvailable(hws []*internal.Hardwarese) bool {
 }
 
 // 进行简单的压缩测试
-func RunCompressTest() bool {
+func (c *Client) RunCompressTest() bool {
+	logger := c.logger()
 	filePath := filepath.Join(tmpDir, fileName)
 
 	// 创建文件
 	err := createLargeFile(filePath, fileSize)
 	if err != nil {
-		fmt.Printf("创建测试文件失败: %v\n", err)
+		logger.Error("创建测试文件失败", "file", filePath, "error", err)
 		return false
 	}
-	fmt.Printf("成功创建测试文件: %s\n", filePath)
+	logger.Info("成功创建测试文件", "file", filePath)
 
 	// 在这里可以添加其他操作
 	// 例如：读取文件、修改文件内容等
 
-	log.Println("***********************进行简单的压缩测试***********************")
+	logger.Info("进行简单的压缩测试", "file", filePath)
 	// // 定义相对路径
 	// relPath := "../testfiles/test_15mb.json"
 
 	// // 获取当前工作目录
 	// absPath, err := filepath.Abs(relPath)
-	// log.Println(absPath)
+	// logger.Info(absPath)
 	// if err != nil {
-	// 	log.Printf("获取当前工作目录时出错: %s\n", err)
+	// 	logger.Error("获取当前工作目录时出错", "error", err)
 	// 	return false
 	// }
-	cmd := exec.Command("qzip", filePath)
+	cmd := c.Exec.Command(c.Exec.Qzip(), filePath)
 
 	// 获取命令输出
 	output, err := cmd.Output()
@@ -86,25 +92,30 @@ func RunCompressTest() bool {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			// 获取退出状态码
 			exitCode := exitError.ExitCode()
-			log.Printf("命令执行失败，退出状态码: %d\n", exitCode)
+			logger.Error("命令执行失败", "command", cmd.String(), "exit_code", exitCode)
 		} else {
-			log.Printf("执行命令时出错: %s\n", err)
+			logger.Error("执行命令时出错", "command", cmd.String(), "error", err)
 		}
 		return false
 	}
 
-	// 打印命令输出
-	log.Printf("命令输出:\n%s\n", output)
-	log.Println("***********************压缩测试结束***********************")
+	// 记录命令输出
+	logger.Info("压缩测试结束", "command", cmd.String(), "file", filePath, "output", string(output))
 	return true
 }
 
+// RunCompressTest runs the compress self-test with DefaultClient.
+func RunCompressTest() bool {
+	return DefaultClient.RunCompressTest()
+}
+
 // 进行简单的解压测试
-func RunDecompressTest() bool {
-	log.Println("***********************进行简单的解压测试***********************")
+func (c *Client) RunDecompressTest() bool {
+	logger := c.logger()
 	// 定义需要解压文件的路径
 	relPath := filepath.Join(tmpDir, compressedName)
-	cmd := exec.Command("qzip", "-d", relPath)
+	logger.Info("进行简单的解压测试", "file", relPath)
+	cmd := c.Exec.Command(c.Exec.Qzip(), "-d", relPath)
 	// 获取命令输出
 	output, err := cmd.Output()
 	if err != nil {
@@ -112,27 +123,31 @@ func RunDecompressTest() bool {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			// 获取退出状态码
 			exitCode := exitError.ExitCode()
-			log.Printf("命令执行失败，退出状态码: %d\n", exitCode)
+			logger.Error("命令执行失败", "command", cmd.String(), "exit_code", exitCode)
 		} else {
-			log.Printf("执行命令时出错: %s\n", err)
+			logger.Error("执行命令时出错", "command", cmd.String(), "error", err)
 		}
 		return false
 	}
 
-	// 打印命令输出
-	log.Printf("命令输出:\n%s\n", output)
+	// 记录命令输出
+	logger.Info("解压命令输出", "command", cmd.String(), "file", relPath, "output", string(output))
 	// 删除文件
 	filePath := filepath.Join(tmpDir, fileName)
 	err = os.Remove(filePath)
 	if err != nil {
-		fmt.Printf("删除文件失败: %v\n", err)
+		logger.Error("删除文件失败", "file", filePath, "error", err)
 		return false
 	}
-	fmt.Printf("成功删除文件: %s\n\n", filePath)
-	log.Println("***********************解压测试结束***********************")
+	logger.Info("解压测试结束", "file", filePath)
 	return true
 }
 
+// RunDecompressTest runs the decompress self-test with DefaultClient.
+func RunDecompressTest() bool {
+	return DefaultClient.RunDecompressTest()
+}
+
 func createLargeFile(filePath string, size int64) error {
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -155,7 +170,5 @@ func createLargeFile(filePath string, size int64) error {
 		}
 		bytesWritten += int64(n)
 	}
-	fmt.Println() // 换行
-
 	return nil
 }
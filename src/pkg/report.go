@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// Plan describes a command that would be executed in dry-run mode: the full argv,
+// the working directory, the expected output paths and the sources that would be deleted.
+type Plan = internal.Plan
+
+// Report describes what an operation did, or would do when the Client is in dry-run mode.
+//
+// 操作结果报告
+type Report struct {
+	// DryRun 模式下计划执行的命令，按执行顺序排列
+	Plans []Plan
+	// 每个输入文件（tar 操作为每个归档）的处理结果，DryRun 时为空
+	Results []Result
+	// 批量操作中处理成功的文件，按处理顺序排列
+	Succeeded []string
+	// 批量操作中处理失败的文件及其错误
+	Failed map[string]error
+	// 批量操作 fail-fast 时尚未处理的文件
+	Remaining []string
+	// 增量压缩中未发生变化而跳过的文件
+	Unchanged []string
+	// 增量压缩中删除（DryRun 时为将要删除）的过期压缩文件
+	Removed []string
+	// 执行前已经存在的输出文件及其处理结果（见 Client.Collision）
+	Collisions []Collision
+	// 安全删除模式下因输出无法校验而保留的源文件（见 Client.SafeDelete）
+	Unverified []string
+	// 失败文件的处理顺序，保证 FailedFiles 的顺序稳定
+	failedOrder []string
+}
+
+// FailedFiles returns the files that failed, in the order they were processed.
+func (r *Report) FailedFiles() []string {
+	return append([]string(nil), r.failedOrder...)
+}
+
+// Retry returns the files that should be passed to a later call: the failed files followed
+// by the files that were not processed after a fail-fast stop.
+func (r *Report) Retry() []string {
+	return append(r.FailedFiles(), r.Remaining...)
+}
+
+// 记录失败的文件
+func (r *Report) addFailure(file string, err error) {
+	if r.Failed == nil {
+		r.Failed = make(map[string]error)
+	}
+	if _, ok := r.Failed[file]; !ok {
+		r.failedOrder = append(r.failedOrder, file)
+	}
+	r.Failed[file] = err
+}
+
+// 合并另一次调用的执行计划、结果与冲突
+func (r *Report) merge(other *Report) {
+	r.Plans = append(r.Plans, other.Plans...)
+	r.Results = append(r.Results, other.Results...)
+	r.Collisions = append(r.Collisions, other.Collisions...)
+	r.Unverified = append(r.Unverified, other.Unverified...)
+}
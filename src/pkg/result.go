@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// Result describes one input file processed by qzip or one archive processed by tar.
+//
+// 单个文件的处理结果
+type Result struct {
+	// 输入文件（tar 压缩时为输入目录，tar 解压时为归档文件）
+	Input string
+	// 输出文件（tar 解压时为输出目录），无法确定时为空
+	Output string
+	// 输入字节数
+	BytesIn int64
+	// 输出字节数
+	BytesOut int64
+	// 压缩比：未压缩大小 / 压缩后大小，压缩与解压含义相同；无法计算时为 0
+	Ratio float64
+	// 整个 qzip/tar 进程的墙钟耗时，多文件操作时为同一次调用的总耗时
+	Duration time.Duration
+	// qzip 输出中统计的该文件耗时，无法解析时为 0
+	QzipTime time.Duration
+	// qzip 输出中统计的该文件吞吐量，单位 Mbit/s，无法解析时为 0
+	Throughput float64
+	// 使用的算法，与 qzip -A 的参数一致
+	Algorithm string
+	// 对输出文件的处理：新建、覆盖、跳过或重命名（见 Client.Collision）
+	Decision Decision
+	// 安全删除模式下输出是否通过校验（见 Client.SafeDelete）
+	Verified bool
+	// 安全删除模式下源文件被移动到的隔离路径
+	Quarantined string
+	// 输出的分离签名文件（见 Client.Signature）
+	Signature string
+}
+
+// 根据qzip的输出生成每个文件的结果
+//
+// inputSizes 需要在执行前获取，因为不保留源文件时输入文件会被删除
+func qzipResults(cmd internal.QzipCommand, targets []internal.Target, inputSizes []int64, output string, elapsed time.Duration) []Result {
+	stats := internal.ParseQzipStats(output)
+	results := make([]Result, 0, len(targets))
+	for i, target := range targets {
+		result := Result{
+			Input:     target.Input,
+			Output:    target.Output,
+			BytesIn:   inputSizes[i],
+			Duration:  elapsed,
+			Algorithm: cmd.Algorithm.String(),
+		}
+		if target.Output != "" {
+			result.BytesOut = sizeOf(target.Output)
+		}
+		// qzip 按文件处理顺序输出统计信息，数量不一致时无法对应
+		if len(stats) == len(targets) {
+			result.QzipTime = stats[i].Time
+			result.Throughput = stats[i].Throughput
+		}
+		result.Ratio = ratio(result.BytesIn, result.BytesOut, cmd.Compression)
+		results = append(results, result)
+	}
+	return results
+}
+
+// 根据tar的输出生成归档结果
+func tarResult(cmd internal.TarCommand, output string, elapsed time.Duration) Result {
+	result := Result{
+		Duration:  elapsed,
+		Algorithm: internal.GetDefaultQzipCommand().Algorithm.String(),
+	}
+	if cmd.Compression {
+		result.Input = strings.Join(cmd.InputFile, " ")
+		result.Output = cmd.ArchiveFile
+		for _, input := range cmd.InputFile {
+			result.BytesIn += treeSize(input)
+		}
+		result.BytesOut = sizeOf(cmd.ArchiveFile)
+	} else {
+		result.Input = cmd.ArchiveFile
+		result.Output = cmd.OutputFile
+		if result.Output == "" {
+			result.Output = filepath.Dir(cmd.ArchiveFile)
+		}
+		result.BytesIn = sizeOf(cmd.ArchiveFile)
+		// tar -v 每行输出一个解压出的条目
+		for _, entry := range strings.Split(output, "\n") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				result.BytesOut += sizeOf(filepath.Join(result.Output, entry))
+			}
+		}
+	}
+	result.Ratio = ratio(result.BytesIn, result.BytesOut, cmd.Compression)
+	return result
+}
+
+// 计算压缩比：未压缩大小 / 压缩后大小
+func ratio(in, out int64, compression bool) float64 {
+	if !compression {
+		in, out = out, in
+	}
+	if out == 0 {
+		return 0
+	}
+	return float64(in) / float64(out)
+}
+
+// 获取普通文件大小，不存在或不是普通文件时返回 0
+func sizeOf(path string) int64 {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+// 获取文件或目录下全部普通文件的大小之和
+func treeSize(root string) int64 {
+	var size int64
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			size += sizeOf(path)
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,276 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// TarRoot maps a source file or directory to its path inside an archive, see Client.CompressRootsByTar.
+//
+// 归档的输入及其在归档中的路径
+type TarRoot struct {
+	// 源文件或目录
+	Source string
+	// 在归档中的路径（以 / 分隔），如 logs/app；为空时使用 Source 的文件名
+	Prefix string
+}
+
+// ErrEntryCollision is returned when two inputs of an archive map to the same entry.
+var ErrEntryCollision = internal.ErrEntryCollision
+
+// 在归档中的路径：相对路径，不能包含 ..
+func (r TarRoot) name() (string, error) {
+	if r.Prefix == "" {
+		return filepath.Base(r.Source), nil
+	}
+	name := path.Clean(filepath.ToSlash(r.Prefix))
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("invalid archive path %q for %s", r.Prefix, r.Source)
+	}
+	return name, nil
+}
+
+// 归档中的一个条目
+type tarEntry struct {
+	// 归档中的名称，目录以 / 结尾
+	name string
+	// 源路径，Prefix 隐含的上级目录为其输入的源路径
+	path string
+	info fs.FileInfo
+	// 是否为 Prefix 隐含的上级目录
+	parent bool
+}
+
+// CompressRootsByTar archives several files or directories, possibly from different parent directories,
+// into one compressed tar archive. Every root is stored under its Prefix: with
+//
+//	TarRoot{Source: "/var/log/app", Prefix: "logs/app"}
+//
+// /var/log/app/today.log becomes logs/app/today.log, and the parent directory logs/ is archived too.
+// Roots that map to the same directory are merged; any other entry archived twice is rejected with
+// ErrEntryCollision before anything is written.
+//
+// The tar stream is produced in Go and compressed by qzip reading from its standard input; symbolic
+// links are stored as links, hard links as separate files and sockets are skipped. The output name
+// follows CompressDictoryByTar, and Client.Reproducible, Encryption, Manifest and Signature apply.
+// With DryRun the plan lists the sources in Plan.Inputs.
+//
+// 将多个文件或目录按给定的路径压缩到同一个 tar 归档中
+func (c *Client) CompressRootsByTar(outputFile string, roots ...TarRoot) (*Report, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("input file is empty")
+	}
+	if outputFile == "" {
+		return nil, errors.New("output file is empty")
+	}
+	entries, err := tarEntries(roots, c.Reproducible)
+	if err != nil {
+		return nil, err
+	}
+	archive := c.archiveName(outputFile)
+	sources := make([]string, len(roots))
+	for i, root := range roots {
+		sources[i] = root.Source
+	}
+	cmd := c.qzipCommand()
+	cmd.Compression = true
+	cmd.KeepSource = false
+
+	report := &Report{}
+	if c.DryRun {
+		process := cmd.BuildQzipCommand()
+		report.Plans = append(report.Plans, Plan{Argv: process.Args, Inputs: sources, Outputs: []string{archive}})
+		return report, nil
+	}
+	start := time.Now()
+	archive, decision, err := c.writeOutput(report, archive, c.encrypted(func(w io.Writer) error {
+		process := cmd.BuildQzipCommand()
+		pr, pw := io.Pipe()
+		var stderr bytes.Buffer
+		process.Stdin, process.Stdout, process.Stderr = pr, w, &stderr
+		written := make(chan error, 1)
+		go func() {
+			err := writeTarEntries(pw, entries, c.Reproducible)
+			pw.CloseWithError(err)
+			written <- err
+		}()
+		err := c.runStreamProcess(process, &stderr, sources)
+		// qzip 提前退出时结束仍在写入的 tar 数据流
+		pr.CloseWithError(io.ErrClosedPipe)
+		if werr := <-written; werr != nil {
+			return werr
+		}
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if decision == DecisionSkipped {
+		report.Results = append(report.Results, Result{Input: strings.Join(sources, " "), Output: archive, Decision: decision})
+		return report, nil
+	}
+	result := tarResult(internal.TarCommand{Compression: true, InputFile: sources, ArchiveFile: archive}, "", time.Since(start))
+	result.Decision = decision
+	report.Results = append(report.Results, result)
+	if err := c.finishCompress(report, true); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompressRootsByTar archives the roots into outputFile with DefaultClient,
+// see Client.CompressRootsByTar.
+func CompressRootsByTar(outputFile string, roots ...TarRoot) error {
+	_, err := DefaultClient.CompressRootsByTar(outputFile, roots...)
+	return err
+}
+
+// 展开各输入为归档条目并检查冲突：Prefix 的每一级上级目录也作为目录条目，同名的目录合并，
+// 其他同名条目返回 ErrEntryCollision；sorted 为 true 时全部条目按名称排序，否则按输入顺序，
+// 每个输入内按名称排序
+func tarEntries(roots []TarRoot, sorted bool) ([]tarEntry, error) {
+	var entries []tarEntry
+	sources := make(map[string]tarEntry)
+	// 加入一个条目，检查与已有条目的冲突
+	add := func(entry tarEntry) error {
+		dir := entry.info.IsDir()
+		if prev, ok := sources[entry.name]; ok {
+			if dir && prev.info.IsDir() {
+				return nil
+			}
+			return fmt.Errorf("%w: %s and %s are both archived as %s", ErrEntryCollision, prev.path, entry.path, entry.name)
+		}
+		// 目录与同名的文件同样冲突
+		other := strings.TrimSuffix(entry.name, "/")
+		if !dir {
+			other += "/"
+		}
+		if prev, ok := sources[other]; ok {
+			return fmt.Errorf("%w: %s and %s are both archived as %s", ErrEntryCollision, prev.path, entry.path, strings.TrimSuffix(entry.name, "/"))
+		}
+		sources[entry.name] = entry
+		entries = append(entries, entry)
+		return nil
+	}
+	for _, root := range roots {
+		name, err := root.name()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(name, "/") {
+			// 上级目录使用输入所在目录的属主与修改时间
+			info, err := os.Stat(filepath.Dir(root.Source))
+			if err != nil {
+				return nil, err
+			}
+			for i, c := range name {
+				if c != '/' {
+					continue
+				}
+				if err := add(tarEntry{name: name[:i+1], path: root.Source, info: info, parent: true}); err != nil {
+					return nil, err
+				}
+			}
+		}
+		err = filepath.WalkDir(root.Source, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// 与 tar 相同，跳过套接字
+			if d.Type()&fs.ModeSocket != 0 {
+				return nil
+			}
+			rel, err := filepath.Rel(root.Source, p)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entry := tarEntry{name: path.Join(name, filepath.ToSlash(rel)), path: p, info: info}
+			if d.IsDir() {
+				entry.name += "/"
+			}
+			return add(entry)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if sorted {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	}
+	return entries, nil
+}
+
+// 写入 tar 数据流；reproducible 为 true 时按 tar --mtime=@0 --owner=0 --group=0 --numeric-owner 统一条目的元数据，
+// 权限与 tar 可复现选项相同
+func writeTarEntries(w io.Writer, entries []tarEntry, reproducible bool) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		link := ""
+		if entry.info.Mode()&fs.ModeSymlink != 0 {
+			var err error
+			if link, err = os.Readlink(entry.path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(entry.info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.path, err)
+		}
+		header.Name = entry.name
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		if entry.parent {
+			header.Mode = 0o755
+		}
+		if reproducible {
+			header.ModTime = time.Unix(0, 0)
+			header.Uid, header.Gid = 0, 0
+			header.Uname, header.Gname = "", ""
+			header.Mode = reproducibleMode(header.Mode, entry.info.IsDir())
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("%s: %w", entry.path, err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if err := copyFile(tw, entry.path, header.Size); err != nil {
+				return fmt.Errorf("%s: %w", entry.path, err)
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// 可复现归档的权限：u+rw,go-w,a+rX,a-st
+func reproducibleMode(mode int64, dir bool) int64 {
+	mode = (mode | 0o644) &^ 0o7022
+	if dir || mode&0o111 != 0 {
+		mode |= 0o111
+	}
+	return mode
+}
+
+// 写入文件的 size 字节，文件在写入过程中变短时返回错误
+func copyFile(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(w, f, size)
+	return err
+}
@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// SafeDeleteOptions configures how sources are removed once their output was verified.
+//
+// 安全删除选项
+type SafeDeleteOptions struct {
+	// 隔离目录，不为空时源文件移动到该目录而不是直接删除
+	QuarantineDir string
+	// 隔离文件的保留时间，每次移入文件时删除超过保留时间的文件；为 0 时永久保留
+	Retention time.Duration
+}
+
+// 隔离子目录的名称格式：源文件移动到以移入时间（UTC）命名的子目录中，
+// 保留时间按子目录名称计算，文件本身保留原来的修改时间
+const quarantineLayout = "20060102T150405Z"
+
+// 校验 src 与其输出 output：压缩时解压 output 与 src 比较，解压时解压 src 与 output 比较
+func verifyPair(compression bool, src, output string) (bool, error) {
+	if compression {
+		return verifyOutput(output, src)
+	}
+	return verifyOutput(src, output)
+}
+
+// 比较解压后的内容与原始文件的 CRC32 和大小
+//
+// compressed 为 gzip（包括 gzipext）或 LZ4s（以 .lz4s 后缀识别）时在 Go 中解压；
+// 其他格式（如 LZ4）无法校验，返回 false 且不返回错误
+func verifyOutput(compressed, plain string) (bool, error) {
+	format, err := Identify(compressed)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(compressed)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var zr io.Reader
+	switch format {
+	case FormatGzip, FormatGzipExt:
+		// gzip.Reader 在每个成员结束时校验其 CRC32 与 ISIZE
+		gr, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return false, fmt.Errorf("verify %s: %w", compressed, err)
+		}
+		zr = gr
+	case FormatLz4s:
+		lr := lz4s.NewReader(f)
+		defer lr.Close()
+		zr = lr
+	default:
+		return false, nil
+	}
+	wantCRC, wantSize, err := checksum(zr)
+	if err != nil {
+		return false, fmt.Errorf("verify %s: %w", compressed, err)
+	}
+	p, err := os.Open(plain)
+	if err != nil {
+		return false, err
+	}
+	defer p.Close()
+	gotCRC, gotSize, err := checksum(p)
+	if err != nil {
+		return false, err
+	}
+	if gotCRC != wantCRC || gotSize != wantSize {
+		return false, fmt.Errorf("verify %s: content of %s does not match (crc32 %08x/%08x, size %d/%d)",
+			compressed, plain, gotCRC, wantCRC, gotSize, wantSize)
+	}
+	return true, nil
+}
+
+// 计算 CRC32 与字节数
+func checksum(r io.Reader) (uint32, int64, error) {
+	h := crc32.NewIEEE()
+	n, err := io.Copy(h, r)
+	return h.Sum32(), n, err
+}
+
+// 校验处理结果的输出，通过后删除或隔离源文件，无法校验时保留源文件
+func (c *Client) safeRemove(compression bool, result *Result) error {
+	verified, err := verifyPair(compression, result.Input, result.Output)
+	if err != nil {
+		return err
+	}
+	return c.removeVerified(result, verified)
+}
+
+// 输出已经校验时删除或隔离源文件，否则保留源文件
+func (c *Client) removeVerified(result *Result, verified bool) error {
+	result.Verified = verified
+	if !verified {
+		c.logger().Warn("output cannot be verified, keeping source", "file", result.Input, "output", result.Output)
+		return nil
+	}
+	quarantined, err := c.disposeSource(result.Input)
+	result.Quarantined = quarantined
+	return err
+}
+
+// 删除已经校验的源文件，设置了隔离目录时移动到隔离目录并返回隔离路径
+func (c *Client) disposeSource(src string) (string, error) {
+	opts := c.SafeDelete
+	if opts == nil || opts.QuarantineDir == "" {
+		return "", os.Remove(src)
+	}
+	dir := filepath.Join(opts.QuarantineDir, time.Now().UTC().Format(quarantineLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, filepath.Base(src))
+	if internal.FileIsExist(dst) {
+		var err error
+		if dst, err = freeName(dst); err != nil {
+			return "", err
+		}
+	}
+	if err := moveFile(src, dst); err != nil {
+		return "", err
+	}
+	if opts.Retention > 0 {
+		if _, err := PurgeQuarantine(opts.QuarantineDir, opts.Retention); err != nil {
+			c.logger().Warn("failed to purge quarantine", "dir", opts.QuarantineDir, "error", err)
+		}
+	}
+	return dst, nil
+}
+
+// 移动文件，跨文件系统时复制后删除源文件，并保留修改时间
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := os.Chtimes(dst, time.Time{}, info.ModTime()); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// PurgeQuarantine removes the files of the quarantine directory dir that were quarantined more than
+// retention ago, and returns the removed paths. Quarantined files keep their original modification
+// time; their age is taken from the timestamped subdirectory they were moved into. Nothing else in
+// dir is touched.
+//
+// 删除隔离目录中超过保留时间的文件
+func PurgeQuarantine(dir string, retention time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(-retention)
+	var removed []string
+	var errs []error
+	for _, entry := range entries {
+		// 只处理以移入时间命名的子目录
+		if !entry.IsDir() {
+			continue
+		}
+		quarantined, err := time.Parse(quarantineLayout, entry.Name())
+		if err != nil || !quarantined.Before(deadline) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		files, err := os.ReadDir(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, file := range files {
+			removed = append(removed, filepath.Join(path, file.Name()))
+		}
+	}
+	return removed, errors.Join(errs...)
+}
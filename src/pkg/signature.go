@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SignatureExtension is appended to the path of a signed file to name its detached signature.
+const SignatureExtension = ".sig"
+
+// ErrSignature is returned, wrapped with the path, when a file does not match its signature.
+var ErrSignature = errors.New("signature verification failed")
+
+// SignatureOptions configures the ed25519 signing of outputs and the verification of archives.
+//
+// 签名选项
+type SignatureOptions struct {
+	// 签名私钥，不为 nil 时压缩输出与校验清单在生成后签名，签名写入旁边的 .sig 文件
+	PrivateKey ed25519.PrivateKey
+	// 验证公钥，不为 nil 时 DecompressDictoryByTar 在解压前、VerifyManifest 在校验前验证签名
+	PublicKey ed25519.PublicKey
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM file in PKCS #8 form
+// (openssl genpkey -algorithm ed25519).
+//
+// 读取签名私钥
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM file in PKIX form
+// (openssl pkey -pubout).
+//
+// 读取验证公钥
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 public key", path)
+	}
+	return public, nil
+}
+
+// 读取 PEM 文件中指定类型的块
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no %s PEM block", path, blockType)
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
+
+// SignFile signs the file at path with key and writes the detached signature to
+// path+SignatureExtension, returning its path.
+//
+// The file is hashed with SHA-512 and signed with Ed25519ph (RFC 8032), so large archives are never
+// held in memory; the signature file holds the base64 encoded signature on one line.
+//
+// 为文件生成分离签名
+func SignFile(path string, key ed25519.PrivateKey) (string, error) {
+	digest, err := fileSHA512(path)
+	if err != nil {
+		return "", err
+	}
+	signature, err := key.Sign(nil, digest, &ed25519.Options{Hash: crypto.SHA512})
+	if err != nil {
+		return "", err
+	}
+	sigPath := path + SignatureExtension
+	tmp := sigPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), 0o644); err != nil {
+		return "", err
+	}
+	return sigPath, os.Rename(tmp, sigPath)
+}
+
+// VerifyFile checks the file at path against its detached signature path+SignatureExtension.
+// It returns ErrSignature when the file or the signature was modified, and an fs.ErrNotExist
+// error when the signature is missing.
+//
+// 验证文件的分离签名
+func VerifyFile(path string, key ed25519.PublicKey) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifyReader(path, f, key)
+}
+
+// 使用 path 的分离签名验证 r 中的内容，r 读取到末尾
+func verifyReader(path string, r io.Reader, key ed25519.PublicKey) error {
+	data, err := os.ReadFile(path + SignatureExtension)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: %s: malformed signature", ErrSignature, path)
+	}
+	h := sha512.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if err := ed25519.VerifyWithOptions(key, h.Sum(nil), signature, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+		return fmt.Errorf("%w: %s", ErrSignature, path)
+	}
+	return nil
+}
+
+// 计算文件内容的 SHA-512
+func fileSHA512(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// 为压缩输出与清单签名，签名路径记录在 Result.Signature 中；跳过的文件不签名
+func (c *Client) signOutputs(report *Report, manifestPath string) error {
+	if c.Signature == nil || c.Signature.PrivateKey == nil || c.DryRun {
+		return nil
+	}
+	for i := range report.Results {
+		result := &report.Results[i]
+		if result.Decision == DecisionSkipped || result.Output == "" {
+			continue
+		}
+		sigPath, err := SignFile(result.Output, c.Signature.PrivateKey)
+		if err != nil {
+			return err
+		}
+		result.Signature = sigPath
+	}
+	if manifestPath != "" {
+		if _, err := SignFile(manifestPath, c.Signature.PrivateKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 配置了验证公钥时验证文件的签名
+func (c *Client) verifySignature(path string) error {
+	if c.Signature == nil || c.Signature.PublicKey == nil {
+		return nil
+	}
+	return VerifyFile(path, c.Signature.PublicKey)
+}
+
+// 配置了验证公钥时验证已打开的文件 f 的签名，path 为其路径；f 从当前位置读取到末尾
+//
+// 验证与后续读取使用同一个打开的文件，验证之后被替换的文件不会被读取
+func (c *Client) verifySignatureFile(path string, f *os.File) error {
+	if c.Signature == nil || c.Signature.PublicKey == nil {
+		return nil
+	}
+	return verifyReader(path, f, c.Signature.PublicKey)
+}
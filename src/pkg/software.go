@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"os"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/gzipext"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// 是否可以在 Go 中解压：Software 模式下的 gzip、gzipext 与 LZ4s 文件
+func (c *Client) softwareDecompresses(input string) bool {
+	if !c.Software {
+		return false
+	}
+	format, err := Identify(input)
+	return err == nil && c.softwareFormat(format)
+}
+
+// Software 模式下是否在 Go 中解压该格式
+func (c *Client) softwareFormat(format Format) bool {
+	return c.Software && (format == FormatGzip || format == FormatGzipExt || format == FormatLz4s)
+}
+
+// 在 Go 中解压 gzip/gzipext/LZ4s 文件，不需要 qzip 与 QAT 设备
+func decompressSoftware(src, dst string) error {
+	format, err := Identify(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if format == FormatLz4s {
+		_, err = lz4s.Decompress(out, in)
+	} else {
+		_, err = gzipext.Decompress(out, in, 0)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
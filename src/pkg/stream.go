@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/gzipext"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// 打开压缩文件的解压数据流：Software 模式下在 Go 中解压，否则由 qzip -d 从标准输入解压到标准输出；
+// 加密的文件先使用 Client.Encryption 的密钥解密
+//
+// 读取到结尾时才能得知解压是否成功；不是压缩格式的文件返回 ErrNotCompressed
+func (c *Client) openDecompressed(path string) (io.ReadCloser, Format, error) {
+	format, err := Identify(path)
+	if err != nil {
+		return nil, format, err
+	}
+	if !format.Compressed() && format != FormatEncrypted {
+		return nil, format, fmt.Errorf("%w: %s (format %s)", ErrNotCompressed, path, format)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, format, err
+	}
+	var src io.Reader = f
+	if format == FormatEncrypted {
+		if src, format, err = c.decryptReader(f); err != nil {
+			f.Close()
+			return nil, format, fmt.Errorf("%s: %w", path, err)
+		}
+		if !format.Compressed() {
+			f.Close()
+			return nil, format, fmt.Errorf("%w: %s (encrypted %s)", ErrNotCompressed, path, format)
+		}
+	}
+	if c.softwareFormat(format) {
+		if format == FormatLz4s {
+			return &softwareReader{Reader: lz4s.NewReader(src), file: f}, format, nil
+		}
+		return &softwareReader{Reader: gzipext.NewReader(src, 0), file: f}, format, nil
+	}
+
+	cmd := c.qzipCommand()
+	cmd.Compression = false
+	cmd.KeepSource = false
+	switch format {
+	case FormatLz4:
+		cmd.Algorithm, cmd.FileHeader = internal.LZ4, internal.FILE_HEADER_LZ4
+	case FormatLz4s:
+		cmd.Algorithm, cmd.FileHeader = internal.LZ4S, internal.FILE_HEADER_LZ4S
+	}
+	process := cmd.BuildQzipCommand()
+	process.Stdin = src
+	r := &commandReader{cmd: process, file: f}
+	process.Stderr = &r.stderr
+	if r.stdout, err = process.StdoutPipe(); err != nil {
+		f.Close()
+		return nil, format, err
+	}
+	c.logger().Debug("executing", "args", process.Args)
+	if err := process.Start(); err != nil {
+		f.Close()
+		return nil, format, err
+	}
+	return r, format, nil
+}
+
+// Go 解码器的数据流，关闭时同时关闭文件
+type softwareReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *softwareReader) Close() error {
+	if closer, ok := r.Reader.(io.Closer); ok {
+		closer.Close()
+	}
+	return r.file.Close()
+}
+
+// qzip 子进程输出的数据流：读取到结尾时等待子进程结束，退出状态不为 0 时返回错误
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	file   *os.File
+	waited bool
+	err    error
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	if r.waited {
+		return 0, r.eof()
+	}
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		r.wait()
+		return n, r.eof()
+	}
+	return n, err
+}
+
+// 等待子进程结束
+func (r *commandReader) wait() {
+	r.waited = true
+	if err := r.cmd.Wait(); err != nil {
+		r.err = fmt.Errorf("%s: %w: %s", r.cmd.Path, err, strings.TrimSpace(r.stderr.String()))
+	}
+}
+
+// 子进程成功结束时返回 io.EOF
+func (r *commandReader) eof() error {
+	if r.err != nil {
+		return r.err
+	}
+	return io.EOF
+}
+
+// Close 在没有读取到结尾时终止子进程
+func (r *commandReader) Close() error {
+	defer r.file.Close()
+	if r.waited {
+		return nil
+	}
+	r.cmd.Process.Kill()
+	r.waited = true
+	r.cmd.Wait()
+	return nil
+}
+
+// 执行通过管道读写数据的子进程，记录与 internal.RunTarCommand 相同的日志
+func (c *Client) runStreamProcess(process *exec.Cmd, stderr *bytes.Buffer, files []string) error {
+	logger := c.logger()
+	logger.Info("executing command", "command", process.String(), "dir", process.Dir, "file", files)
+	start := time.Now()
+	err := process.Run()
+	duration := time.Since(start)
+	if err != nil {
+		logger.Error("command failed", "command", process.String(), "file", files, "duration", duration, "error", err, "output", stderr.String())
+		return fmt.Errorf("error executing command: %s, output: %s", err, stderr.String())
+	}
+	logger.Info("command finished", "command", process.String(), "file", files, "duration", duration)
+	return nil
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// CompressTree compresses the files of srcDir selected by opts into dstDir, reproducing the relative
+// directory structure: srcDir/a/b.log becomes dstDir/a/b.log.gz. srcDir is only read; dstDir must not
+// be srcDir or lie inside it, otherwise the next run would compress the previous outputs again.
+//
+// Every file is compressed with its own qzip invocation (-o only works for a single file),
+// Client.Parallelism at a time. A failed file does not stop the others; the report tells which
+// files succeeded and failed.
+//
+// qzip -k -o dstDir/a/b.log srcDir/a/b.log
+//
+// 将目录压缩到另一个目录，保持相对目录结构
+func (c *Client) CompressTree(srcDir, dstDir string, opts WalkOptions) (*Report, error) {
+	return c.runTree(srcDir, dstDir, opts, true)
+}
+
+// DecompressTree decompresses the compressed files of srcDir selected by opts into dstDir, reproducing
+// the relative directory structure: srcDir/a/b.log.gz becomes dstDir/a/b.log. srcDir is only read.
+//
+// Files without a known compressed suffix are ignored. See CompressTree for the execution details.
+//
+// qzip -d -k -o dstDir/a/b.log srcDir/a/b.log.gz
+//
+// 将目录解压到另一个目录，保持相对目录结构
+func (c *Client) DecompressTree(srcDir, dstDir string, opts WalkOptions) (*Report, error) {
+	return c.runTree(srcDir, dstDir, opts, false)
+}
+
+// CompressTree compresses srcDir into dstDir with DefaultClient, see Client.CompressTree.
+func CompressTree(srcDir, dstDir string, opts WalkOptions) (*Report, error) {
+	return DefaultClient.CompressTree(srcDir, dstDir, opts)
+}
+
+// DecompressTree decompresses srcDir into dstDir with DefaultClient, see Client.DecompressTree.
+func DecompressTree(srcDir, dstDir string, opts WalkOptions) (*Report, error) {
+	return DefaultClient.DecompressTree(srcDir, dstDir, opts)
+}
+
+// 将 srcDir 下选中的文件逐个压缩或解压到 dstDir 的对应位置
+func (c *Client) runTree(srcDir, dstDir string, opts WalkOptions, compression bool) (*Report, error) {
+	if dstDir == "" {
+		return nil, fmt.Errorf("output directory is empty")
+	}
+	if compression {
+		if err := c.checkManifest(); err != nil {
+			return nil, err
+		}
+	}
+	if inside, err := insideDir(srcDir, dstDir); err != nil {
+		return nil, err
+	} else if inside {
+		return nil, fmt.Errorf("output directory %s is inside the input directory %s", dstDir, srcDir)
+	}
+	files, err := SelectFiles(srcDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !compression {
+		// 解压只处理带有压缩后缀的文件
+		compressed := files[:0]
+		for _, file := range files {
+			if internal.TrimCompressedSuffix(file) != file {
+				compressed = append(compressed, file)
+			}
+		}
+		files = compressed
+	}
+	report := &Report{}
+	err = c.runTreeFiles(report, srcDir, dstDir, files, compression)
+	if compression {
+		// 失败的文件不影响其他文件，清单与签名只包括压缩成功的文件
+		err = errors.Join(err, c.finishCompress(report, false))
+	}
+	return report, err
+}
+
+// 将 srcDir 下的指定文件逐个压缩或解压到 dstDir 的对应位置
+func (c *Client) runTreeFiles(report *Report, srcDir, dstDir string, files []string, compression bool) error {
+	return c.runEach(report, BatchOptions{ContinueOnError: true}, files, func(inputFile string) (internal.QzipCommand, error) {
+		rel, err := filepath.Rel(srcDir, inputFile)
+		if err != nil {
+			return internal.QzipCommand{}, err
+		}
+		// 压缩：qzip 会在 -o 指定的名称后追加后缀；解压：-o 即为输出文件
+		output := filepath.Join(dstDir, rel)
+		if !compression {
+			if output, err = internal.StripCompressedSuffix(output); err != nil {
+				return internal.QzipCommand{}, err
+			}
+		}
+		if !c.DryRun {
+			if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+				return internal.QzipCommand{}, err
+			}
+		}
+		cmd := c.qzipCommand()
+		cmd.Compression = compression
+		// 源目录只读，必须保留源文件
+		cmd.KeepSource = true
+		cmd.InputFile = append(cmd.InputFile, inputFile)
+		cmd.OutputFile = output
+		return cmd, nil
+	})
+}
+
+// 判断 dst 是否为 src 或位于 src 之中：逐级检查 dst 及其上级目录（包括经过符号链接的路径），
+// 否则下一次遍历 src 时会再次处理 dst 中的输出
+func insideDir(src, dst string) (bool, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	dir, err := filepath.Abs(dst)
+	if err != nil {
+		return false, err
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err == nil && os.SameFile(srcInfo, info) {
+			return true, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// IntegrityError describes where a compressed file failed the integrity test, see Client.Verify.
+//
+// 压缩文件损坏的位置
+type IntegrityError struct {
+	// 压缩文件路径
+	Path string
+	// 损坏的 gzip 成员或 LZ4 帧在压缩文件中的偏移，无法确定或文件已加密时为 -1
+	Offset int64
+	// 读取出错时所在的 tar 条目名称，不是 tar 归档或条目头部已经损坏时为空
+	Entry string
+	// 读取出错时所在 tar 条目的头部在解压后数据中的偏移，不是 tar 归档时为 -1
+	EntryOffset int64
+	// 解压或读取 tar 归档的错误
+	Err error
+}
+
+func (e *IntegrityError) Error() string {
+	msg := e.Path + ": "
+	if e.Offset >= 0 {
+		msg += fmt.Sprintf("corrupt data at offset %d: ", e.Offset)
+	}
+	if e.EntryOffset >= 0 {
+		msg += fmt.Sprintf("tar entry %q at offset %d: ", e.Entry, e.EntryOffset)
+	}
+	return msg + e.Err.Error()
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return e.Err
+}
+
+// tar 头部块大小
+const tarBlockSize = 512
+
+// Verify tests the integrity of compressed files without writing the decompressed data: each file
+// is decompressed into a discard sink by qzip, or in Go with Client.Software, which checks the
+// CRC32 and sizes of every gzip member or the checksums of every LZ4 frame. When the content is a
+// tar archive (.tgz), every entry is read as well. Encrypted files are decrypted first with
+// Client.Encryption. Directories are searched for compressed files.
+//
+// Failed files are listed in Report.Failed with an *IntegrityError that tells the corrupt member
+// and tar entry; Results holds the sizes of every file that passed. Client.Parallelism files are
+// tested at a time, and opts controls whether testing stops at the first corrupt file.
+//
+// qzip -d < file > /dev/null
+//
+// 校验压缩文件与归档的完整性
+func (c *Client) Verify(opts BatchOptions, paths ...string) (*Report, error) {
+	report := &Report{}
+	files, err := verifyTargets(paths)
+	if err != nil {
+		return report, err
+	}
+	err = c.forEach(report, opts, files, func(r *Report, file string) error {
+		result, err := c.verifyFile(file)
+		if err != nil {
+			return err
+		}
+		r.Results = append(r.Results, result)
+		return nil
+	})
+	return report, err
+}
+
+// Verify tests the integrity of compressed files with DefaultClient, see Client.Verify.
+func Verify(opts BatchOptions, paths ...string) (*Report, error) {
+	return DefaultClient.Verify(opts, paths...)
+}
+
+// 展开需要校验的文件：目录中所有压缩格式的文件，以及直接指定的文件
+func verifyTargets(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		selected, err := SelectFiles(path, WalkOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range selected {
+			if format, err := Identify(file); err == nil && format.Compressed() {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// 校验单个文件：解压到丢弃的输出，内容为 tar 归档时读取每个条目
+func (c *Client) verifyFile(path string) (Result, error) {
+	start := time.Now()
+	result := Result{Input: path, BytesIn: sizeOf(path)}
+	rc, format, err := c.openDecompressed(path)
+	if err != nil {
+		return result, err
+	}
+	defer rc.Close()
+	result.Algorithm = format.String()
+
+	br := bufio.NewReaderSize(rc, 1<<16)
+	cr := &countingReader{r: br}
+	entry, entryOffset := "", int64(-1)
+	header, _ := br.Peek(identifySize)
+	if identify(header) == FormatTar {
+		entry, entryOffset, err = walkTar(cr)
+	}
+	if err == nil {
+		// tar 归档结束标记之后的数据也需要读取，才能校验最后一个成员的 CRC32
+		_, err = io.Copy(io.Discard, cr)
+	}
+	if err == nil {
+		err = rc.Close()
+	}
+	if err != nil {
+		return result, &IntegrityError{
+			Path:        path,
+			Offset:      locateCorruption(path),
+			Entry:       entry,
+			EntryOffset: entryOffset,
+			Err:         err,
+		}
+	}
+	result.BytesOut = cr.n
+	result.Ratio = ratio(result.BytesIn, result.BytesOut, false)
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// 读取 tar 归档的每个条目，出错时返回所在条目的名称与头部偏移
+func walkTar(cr *countingReader) (string, int64, error) {
+	tr := tar.NewReader(cr)
+	for {
+		// 上一个条目的数据之后补齐到 512 字节
+		offset := (cr.n + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", -1, nil
+		} else if err != nil {
+			return "", offset, err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return hdr.Name, offset, err
+		}
+	}
+}
+
+// 逐个成员或帧重新读取压缩文件，返回第一个损坏的成员或帧的偏移，无法确定时返回 -1；
+// 加密文件中的偏移没有意义，同样返回 -1
+func locateCorruption(path string) int64 {
+	format, err := Identify(path)
+	if err != nil || !format.Compressed() {
+		return -1
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	switch format {
+	case FormatLz4, FormatLz4s:
+		var stats *lz4s.Stats
+		if format == FormatLz4s {
+			stats, err = lz4s.Inspect(f)
+		} else {
+			stats, err = lz4s.Scan(f)
+		}
+		if err == nil || len(stats.Frames) == 0 {
+			return -1
+		}
+		return stats.Frames[len(stats.Frames)-1].Offset
+	}
+	br := bufio.NewReader(f)
+	cr := &countingReader{r: br}
+	zr := new(gzip.Reader)
+	for {
+		if _, err := br.Peek(1); err != nil {
+			return -1
+		}
+		offset := cr.n
+		if err := zr.Reset(cr); err != nil {
+			return offset
+		}
+		zr.Multistream(false)
+		// gzip.Reader 在成员结束时校验 CRC32 与 ISIZE
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			return offset
+		}
+	}
+}
+
+// 记录读取字节数的读取器，实现 io.ByteReader 以免 gzip 多读数据
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SymlinkPolicy tells the directory walker what to do with symbolic links.
+//
+// 符号链接处理策略
+type SymlinkPolicy int
+
+const (
+	// 跳过符号链接（默认）
+	SymlinkSkip SymlinkPolicy = iota
+	// 跟随符号链接：指向文件时选中该链接，指向目录时进入该目录（检测循环）
+	SymlinkFollow
+)
+
+// 已经是压缩文件的后缀，SkipCompressed 时跳过
+var compressedExtensions = []string{".gz", ".tgz", ".lz4", ".lz4s", EncryptedExtension}
+
+// WalkOptions selects the files of a directory tree that are handed to qzip.
+//
+// Glob patterns use filepath.Match syntax. A pattern containing a '/' is matched against the
+// slash-separated path relative to the root, any other pattern against the base name.
+// Regular expressions are matched against the slash-separated relative path.
+//
+// 目录遍历选项
+type WalkOptions struct {
+	// 包含的 glob 模式，为空时包含全部文件；只作用于文件
+	Include []string
+	// 排除的 glob 模式，作用于文件和目录（排除目录时不再进入该目录）
+	Exclude []string
+	// 包含的正则表达式，为空时包含全部文件；只作用于文件
+	IncludeRegexp []string
+	// 排除的正则表达式，作用于文件和目录
+	ExcludeRegexp []string
+	// 文件大小下限（字节），为 0 时不限制
+	MinSize int64
+	// 文件大小上限（字节），为 0 时不限制
+	MaxSize int64
+	// 最大深度：1 表示只选择根目录下的文件，为 0 时不限制
+	MaxDepth int
+	// 是否包含以 . 开头的隐藏文件和目录
+	IncludeHidden bool
+	// 符号链接处理策略
+	Symlinks SymlinkPolicy
+	// 是否跳过已经压缩的文件（.gz/.tgz/.lz4/.lz4s）
+	SkipCompressed bool
+}
+
+// 编译后的遍历选项
+type walker struct {
+	opts          WalkOptions
+	includeRegexp []*regexp.Regexp
+	excludeRegexp []*regexp.Regexp
+	// 已进入的目录（真实路径），跟随符号链接时用于检测循环
+	visited map[string]bool
+	files   []string
+}
+
+// SelectFiles walks root and returns the regular files selected by opts, in lexical order.
+//
+// 遍历目录并按选项选择文件
+func SelectFiles(root string, opts WalkOptions) ([]string, error) {
+	w := &walker{opts: opts, visited: make(map[string]bool)}
+	var err error
+	if w.includeRegexp, err = compileRegexps(opts.IncludeRegexp); err != nil {
+		return nil, err
+	}
+	if w.excludeRegexp, err = compileRegexps(opts.ExcludeRegexp); err != nil {
+		return nil, err
+	}
+	for _, pattern := range append(append([]string(nil), opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	if err := w.walk(root, root, 0); err != nil {
+		return nil, err
+	}
+	return w.files, nil
+}
+
+// 遍历目录 dir，rel 的计算以 root 为基准，depth 为 dir 相对 root 的深度
+func (w *walker) walk(root, dir string, depth int) error {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if w.visited[real] {
+			return nil
+		}
+		w.visited[real] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !w.opts.IncludeHidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if w.excluded(rel) {
+			continue
+		}
+
+		mode := entry.Type()
+		var info fs.FileInfo
+		if mode&fs.ModeSymlink != 0 {
+			if w.opts.Symlinks != SymlinkFollow {
+				continue
+			}
+			// 跟随符号链接，悬空链接直接跳过
+			if info, err = os.Stat(path); err != nil {
+				continue
+			}
+			mode = info.Mode().Type()
+		}
+
+		if mode.IsDir() {
+			if w.opts.MaxDepth == 0 || depth+1 < w.opts.MaxDepth {
+				if err := w.walk(root, path, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+		if info == nil {
+			if info, err = entry.Info(); err != nil {
+				return err
+			}
+		}
+		if w.selected(rel, info.Size()) {
+			w.files = append(w.files, path)
+		}
+	}
+	return nil
+}
+
+// 文件或目录是否被排除
+func (w *walker) excluded(rel string) bool {
+	for _, pattern := range w.opts.Exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	for _, re := range w.excludeRegexp {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// 文件是否被选中
+func (w *walker) selected(rel string, size int64) bool {
+	if w.opts.SkipCompressed {
+		for _, ext := range compressedExtensions {
+			if strings.HasSuffix(rel, ext) {
+				return false
+			}
+		}
+	}
+	if w.opts.MinSize > 0 && size < w.opts.MinSize {
+		return false
+	}
+	if w.opts.MaxSize > 0 && size > w.opts.MaxSize {
+		return false
+	}
+	if len(w.opts.Include) > 0 {
+		matched := false
+		for _, pattern := range w.opts.Include {
+			if matchGlob(pattern, rel) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range w.includeRegexp {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return len(w.includeRegexp) == 0
+}
+
+// 含有 / 的模式匹配相对路径，否则匹配文件名
+func matchGlob(pattern, rel string) bool {
+	name := rel
+	if !strings.Contains(pattern, "/") {
+		name = rel[strings.LastIndex(rel, "/")+1:]
+	}
+	matched, _ := filepath.Match(pattern, name)
+	return matched
+}
+
+// 编译正则表达式
+func compileRegexps(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// CompressDirectory compresses the files of inputDirectory selected by opts, keeping the original files intact.
+//
+// Unlike CompressDictoryByEveryFile, which hands the directory to qzip -R, the directory is walked in Go
+// and the selected files are handed to qzip in batches (see CompressFiles).
+//
+// qzip -k file1 file2 ...
+//
+// 按过滤规则压缩目录下的文件
+func (c *Client) CompressDirectory(inputDirectory string, opts WalkOptions) (*Report, error) {
+	files, err := SelectFiles(inputDirectory, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return &Report{}, nil
+	}
+	return c.CompressFiles(files...)
+}
+
+// CompressDirectory compresses the selected files of inputDirectory with DefaultClient,
+// see Client.CompressDirectory.
+func CompressDirectory(inputDirectory string, opts WalkOptions) error {
+	_, err := DefaultClient.CompressDirectory(inputDirectory, opts)
+	return err
+}
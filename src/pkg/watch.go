@@ -0,0 +1,280 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+)
+
+// QzipCommand is the qzip command template, see internal.QzipCommand.
+type QzipCommand = internal.QzipCommand
+
+// WatchOptions configures Client.Watch.
+//
+// 目录监控选项
+type WatchOptions struct {
+	// 文件在关闭写入（inotify IN_CLOSE_WRITE）之后再等待的时间，用于合并连续的写入，默认 200ms
+	Debounce time.Duration
+	// 没有关闭事件时（轮询模式或文件仍被打开），文件大小与修改时间保持不变多久后视为写入完成，默认 5s
+	QuietPeriod time.Duration
+	// 忽略的 glob 模式，规则与 WalkOptions.Exclude 相同；已经压缩的文件（.gz/.tgz/.lz4/.lz4s）总是被忽略
+	Ignore []string
+	// 等待压缩的文件队列长度，队列已满时文件保留在等待列表中稍后重试，默认 1024；
+	// 等待列表同样最多保留 QueueSize 个文件，已满时暂停读取事件，直到其中的文件进入队列
+	QueueSize int
+	// 是否监控子目录
+	Recursive bool
+	// 为 true 时不使用 inotify，直接使用轮询
+	Polling bool
+	// 轮询间隔，默认 2s
+	PollInterval time.Duration
+	// qzip 命令模板，为 nil 时使用默认的压缩命令；InputFile/Exec/Logger 由 Watch 设置
+	Command *QzipCommand
+	// 每个文件处理完成后的回调，可以为 nil；会在多个 goroutine 中并发调用
+	OnResult func(file string, report *Report, err error)
+}
+
+// 文件系统事件
+type watchEvent struct {
+	// 文件路径
+	Path string
+	// 文件已关闭写入或被移动到监控目录中，可以在 Debounce 之后处理
+	Closed bool
+	// 文件已被删除或移出监控目录
+	Removed bool
+}
+
+// 事件来源：inotify 或轮询
+type watchBackend interface {
+	// 持续产生事件直到 ctx 结束
+	Run(ctx context.Context, events chan<- watchEvent) error
+}
+
+// 等待压缩的文件
+type pendingFile struct {
+	// 最近一次事件的时间
+	lastEvent time.Time
+	// 是否收到过关闭写入事件
+	closed bool
+	// 最近一次检查时的大小与修改时间，用于判断文件是否稳定
+	size    int64
+	modTime time.Time
+}
+
+// Watch monitors dirs and compresses every new or rewritten file once it was closed for writing and
+// stayed untouched for opts.Debounce, or stayed unchanged for opts.QuietPeriod. Files are compressed
+// with opts.Command (or the default compress command), Client.Parallelism at a time.
+//
+// A rewritten file collides with its previous output, which is handled by Client.Collision:
+// use CollisionOverwrite to replace it.
+//
+// inotify is used on Linux, polling elsewhere or when opts.Polling is set. Watch blocks until ctx is
+// done, then waits for the running compressions and returns ctx.Err().
+//
+// 监控目录并自动压缩新文件
+func (c *Client) Watch(ctx context.Context, opts WatchOptions, dirs ...string) error {
+	if len(dirs) == 0 {
+		return errors.New("no directory to watch")
+	}
+	opts = watchDefaults(opts)
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return errors.New(dir + " is not a directory")
+		}
+	}
+	ignore := func(path string) bool { return watchIgnored(dirs, opts.Ignore, path) }
+
+	var backend watchBackend
+	if !opts.Polling {
+		b, err := newInotifyBackend(dirs, opts.Recursive, ignore, c.logger())
+		if err != nil {
+			c.logger().Warn("inotify unavailable, falling back to polling", "error", err)
+		} else {
+			backend = b
+		}
+	}
+	if backend == nil {
+		backend = newPollBackend(dirs, opts.Recursive, opts.PollInterval, ignore)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := make(chan watchEvent, opts.QueueSize)
+	queue := make(chan string, opts.QueueSize)
+	backendErr := make(chan error, 1)
+	go func() {
+		backendErr <- backend.Run(ctx, events)
+	}()
+
+	var wg sync.WaitGroup
+	workers := c.Parallelism
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				report, err := c.compressWatched(opts, file)
+				if opts.OnResult != nil {
+					opts.OnResult(file, report, err)
+				}
+			}
+		}()
+	}
+
+	err := c.debounce(ctx, opts, events, queue, ignore, backendErr)
+	close(queue)
+	wg.Wait()
+	return err
+}
+
+// 合并事件：文件稳定后放入压缩队列，直到 ctx 结束或事件来源出错
+//
+// 等待列表达到 QueueSize 时不再读取事件，事件来源随之阻塞，等待列表与事件缓冲的大小都有上限
+func (c *Client) debounce(ctx context.Context, opts WatchOptions, events <-chan watchEvent, queue chan<- string, ignore func(string) bool, backendErr <-chan error) error {
+	pending := make(map[string]*pendingFile)
+	tick := opts.Debounce / 2
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		in := events
+		if len(pending) >= opts.QueueSize {
+			in = nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-backendErr:
+			if err == nil {
+				err = ctx.Err()
+			}
+			return err
+		case event := <-in:
+			if ignore(event.Path) {
+				continue
+			}
+			if event.Removed {
+				delete(pending, event.Path)
+				continue
+			}
+			p, ok := pending[event.Path]
+			if !ok {
+				p = &pendingFile{}
+				pending[event.Path] = p
+			}
+			p.lastEvent = time.Now()
+			p.closed = p.closed || event.Closed
+			if info, err := os.Stat(event.Path); err == nil {
+				p.size, p.modTime = info.Size(), info.ModTime()
+			}
+		case now := <-ticker.C:
+			for path, p := range pending {
+				wait := opts.QuietPeriod
+				if p.closed {
+					wait = opts.Debounce
+				}
+				if now.Sub(p.lastEvent) < wait {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					delete(pending, path)
+					continue
+				}
+				// 没有关闭事件时，需要在一个静默期内大小与修改时间都保持不变
+				if !p.closed && (info.Size() != p.size || !info.ModTime().Equal(p.modTime)) {
+					p.size, p.modTime, p.lastEvent = info.Size(), info.ModTime(), now
+					continue
+				}
+				select {
+				case queue <- path:
+					delete(pending, path)
+				default:
+					// 队列已满，保留在等待列表中，下一次检查时重试
+				}
+			}
+		}
+	}
+}
+
+// 使用命令模板压缩单个文件
+func (c *Client) compressWatched(opts WatchOptions, file string) (*Report, error) {
+	cmd := c.qzipCommand()
+	if opts.Command != nil {
+		cmd = *opts.Command
+		cmd.Options = append([]string(nil), opts.Command.Options...)
+		cmd.Exec = c.Exec
+		cmd.Logger = c.Logger
+		cmd.Context = c.ctx
+		if cmd.NameTemplate == "" {
+			cmd.NameTemplate = c.NameTemplate
+		}
+	}
+	cmd.Compression = true
+	cmd.IsDirctory = false
+	cmd.InputFile = []string{file}
+	report := &Report{}
+	err := c.runQzip(report, cmd)
+	if err != nil {
+		c.logger().Error("watch compress failed", "file", file, "error", err)
+	}
+	return report, err
+}
+
+// 填充默认值
+func watchDefaults(opts WatchOptions) WatchOptions {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 200 * time.Millisecond
+	}
+	if opts.QuietPeriod <= 0 {
+		opts.QuietPeriod = 5 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	return opts
+}
+
+// 文件或目录是否被忽略：已经压缩的文件、Atomic 使用的临时目录，或匹配忽略规则
+func watchIgnored(roots, patterns []string, path string) bool {
+	if matched, _ := filepath.Match(atomicTempPattern, filepath.Base(filepath.Dir(path))); matched {
+		return true
+	}
+	if matched, _ := filepath.Match(atomicTempPattern, filepath.Base(path)); matched {
+		return true
+	}
+	for _, ext := range compressedExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	rel := filepath.Base(path)
+	for _, root := range roots {
+		if r, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = filepath.ToSlash(r)
+			break
+		}
+	}
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// 监控的 inotify 事件
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM
+
+// inotify 事件来源
+type inotifyBackend struct {
+	file      *os.File
+	recursive bool
+	ignore    func(string) bool
+	logger    *slog.Logger
+	mu        sync.Mutex
+	// 监控描述符对应的目录
+	watches map[int32]string
+	dirs    []string
+}
+
+// 创建 inotify 实例并监控目录（Recursive 时包含全部子目录）
+func newInotifyBackend(dirs []string, recursive bool, ignore func(string) bool, logger *slog.Logger) (watchBackend, error) {
+	// 非阻塞的文件描述符可以交给 Go 的 poller，关闭文件时阻塞的 Read 会返回
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	b := &inotifyBackend{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		recursive: recursive,
+		ignore:    ignore,
+		logger:    logger,
+		watches:   make(map[int32]string),
+		dirs:      dirs,
+	}
+	for _, dir := range dirs {
+		if err := b.addTree(dir, nil); err != nil {
+			b.file.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// 监控目录；Recursive 时监控全部子目录，found 不为 nil 时收集已经存在的文件
+func (b *inotifyBackend) addTree(dir string, found func(string)) error {
+	if !b.recursive {
+		return b.add(dir)
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && b.ignore(path) {
+				return filepath.SkipDir
+			}
+			return b.add(path)
+		}
+		if found != nil && d.Type().IsRegular() {
+			found(path)
+		}
+		return nil
+	})
+}
+
+// 监控单个目录
+func (b *inotifyBackend) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(int(b.file.Fd()), dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	b.mu.Lock()
+	b.watches[int32(wd)] = dir
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *inotifyBackend) Run(ctx context.Context, events chan<- watchEvent) error {
+	go func() {
+		<-ctx.Done()
+		b.file.Close()
+	}()
+	// 足够容纳多个带有最长文件名的事件
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			name := strings.TrimRight(string(buf[offset+syscall.SizeofInotifyEvent:offset+syscall.SizeofInotifyEvent+nameLen]), "\x00")
+			offset += syscall.SizeofInotifyEvent + nameLen
+
+			for _, event := range b.translate(wd, mask, name) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// 将 inotify 事件转换为文件事件
+func (b *inotifyBackend) translate(wd int32, mask uint32, name string) []watchEvent {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// 事件队列溢出，重新扫描全部目录，由 debounce 判断文件是否稳定
+		b.logger.Warn("inotify queue overflow, rescanning directories")
+		var events []watchEvent
+		for _, dir := range b.dirs {
+			_ = b.addTree(dir, func(path string) { events = append(events, watchEvent{Path: path}) })
+		}
+		return events
+	}
+	b.mu.Lock()
+	dir, ok := b.watches[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(b.watches, wd)
+	}
+	b.mu.Unlock()
+	if !ok || name == "" {
+		return nil
+	}
+	path := filepath.Join(dir, name)
+
+	if mask&syscall.IN_ISDIR != 0 {
+		// 新建或移入的子目录：开始监控，并处理监控建立之前已经写入的文件
+		if b.recursive && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !b.ignore(path) {
+			var events []watchEvent
+			if err := b.addTree(path, func(file string) { events = append(events, watchEvent{Path: file}) }); err != nil {
+				b.logger.Warn("failed to watch directory", "dir", path, "error", err)
+			}
+			return events
+		}
+		return nil
+	}
+	switch {
+	case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		return []watchEvent{{Path: path, Removed: true}}
+	case mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0:
+		return []watchEvent{{Path: path, Closed: true}}
+	default:
+		return []watchEvent{{Path: path}}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pkg
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// 非 Linux 系统没有 inotify，Watch 会使用轮询
+func newInotifyBackend(dirs []string, recursive bool, ignore func(string) bool, logger *slog.Logger) (watchBackend, error) {
+	return nil, errors.New("inotify is only available on linux")
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 轮询事件来源：定期遍历目录，比较文件大小与修改时间
+type pollBackend struct {
+	dirs      []string
+	recursive bool
+	interval  time.Duration
+	ignore    func(string) bool
+}
+
+// 文件快照
+type pollState struct {
+	size    int64
+	modTime time.Time
+}
+
+func newPollBackend(dirs []string, recursive bool, interval time.Duration, ignore func(string) bool) *pollBackend {
+	return &pollBackend{dirs: dirs, recursive: recursive, interval: interval, ignore: ignore}
+}
+
+// 首次遍历只记录已有的文件，之后每次遍历为新增、变化或删除的文件产生事件
+func (p *pollBackend) Run(ctx context.Context, events chan<- watchEvent) error {
+	previous := p.scan()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current := p.scan()
+		for path, state := range current {
+			if old, ok := previous[path]; ok && old == state {
+				continue
+			}
+			select {
+			case events <- watchEvent{Path: path}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		for path := range previous {
+			if _, ok := current[path]; ok {
+				continue
+			}
+			select {
+			case events <- watchEvent{Path: path, Removed: true}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		previous = current
+	}
+}
+
+// 遍历监控目录，记录每个普通文件的大小与修改时间
+func (p *pollBackend) scan() map[string]pollState {
+	states := make(map[string]pollState)
+	for _, dir := range p.dirs {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && (!p.recursive || p.ignore(path)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || p.ignore(path) {
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil
+			}
+			states[path] = pollState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return states
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 写入部分输出后失败（或长时间阻塞）的 qzip
+const brokenQzipScript = `#!/bin/sh
+out=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-o) shift; out="$1" ;;
+	-A|-O|-L|-r|-P) shift ;;
+	-*) ;;
+	*) break ;;
+	esac
+	shift
+done
+echo partial > "${out:-$1}.gz"
+[ -n "$QZIP_HANG" ] && sleep 10
+exit 1
+`
+
+// 目录中除 keep 之外的文件
+func extraFiles(t *testing.T, dir string, keep ...string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var extra []string
+	for _, entry := range entries {
+		found := false
+		for _, name := range keep {
+			found = found || entry.Name() == name
+		}
+		if !found {
+			extra = append(extra, entry.Name())
+		}
+	}
+	return extra
+}
+
+// qzip 失败或操作被取消时不会在最终路径留下不完整的文件
+func TestAtomicOutputCleanup(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.log")
+	writeFile(t, input, "content")
+	qzip := filepath.Join(t.TempDir(), "qzip")
+	if err := os.WriteFile(qzip, []byte(brokenQzipScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Atomic = true
+	client.Exec.QzipPath = qzip
+
+	if _, err := client.CompressFile(input); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if extra := extraFiles(t, dir, "a.log"); len(extra) != 0 {
+		t.Fatalf("partial output left behind: %q", extra)
+	}
+
+	client.Exec.Env = append(os.Environ(), "QZIP_HANG=1")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.WithContext(ctx).CompressFile(input); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("qzip was not killed on cancellation")
+	}
+	if extra := extraFiles(t, dir, "a.log"); len(extra) != 0 {
+		t.Fatalf("partial output left behind: %q", extra)
+	}
+}
+
+// Atomic 模式下 qzip 与 tar 的输出写入最终路径，临时目录被删除
+func TestAtomicOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data", "a.log"), "a")
+	writeFile(t, filepath.Join(dir, "data", "sub", "b.log"), "b")
+	client := pkg.NewClient()
+	client.Atomic = true
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Parallelism = 2
+
+	report, err := client.CompressDictoryByEveryFile(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+	for _, result := range report.Results {
+		if result.Output != result.Input+".gz" || result.BytesOut == 0 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	if extra := extraFiles(t, filepath.Join(dir, "data"), "a.log", "a.log.gz", "sub"); len(extra) != 0 {
+		t.Fatalf("temporary files left behind: %q", extra)
+	}
+
+	archive := filepath.Join(dir, "data.tgz")
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+		t.Fatalf("tar failed: %s", err)
+	}
+	restored := filepath.Join(dir, "restored")
+	if err := os.Mkdir(restored, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DecompressDictoryByTar(archive, restored); err != nil {
+		t.Fatalf("untar failed: %s", err)
+	}
+	data, err := os.ReadFile(filepath.Join(restored, "data", "sub", "b.log"))
+	if err != nil || string(data) != "b" {
+		t.Fatalf("unexpected restored file: %q %v", data, err)
+	}
+	if extra := extraFiles(t, restored, "data"); len(extra) != 0 {
+		t.Fatalf("temporary files left behind: %q", extra)
+	}
+	if extra := extraFiles(t, dir, "data", "data.tgz", "restored"); len(extra) != 0 {
+		t.Fatalf("temporary files left behind: %q", extra)
+	}
+}
+
+// 调用 qzip 之前创建最终输出的 qzip，模拟检查输出之后同名文件才出现的情况
+const racingQzipScript = `#!/bin/sh
+for last; do :; done
+printf other > "$last.gz"
+exec "$FAKE_QZIP" "$@"
+`
+
+// 检查之后才出现的输出文件不会被替换
+func TestAtomicOutputAppears(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.log")
+	writeFile(t, input, "content")
+	qzip := filepath.Join(t.TempDir(), "qzip")
+	if err := os.WriteFile(qzip, []byte(racingQzipScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Atomic = true
+	client.Exec.QzipPath = qzip
+	client.Exec.Env = append(os.Environ(), "FAKE_QZIP="+fakeQzip(t))
+	for _, collision := range []pkg.CollisionPolicy{pkg.CollisionFail, pkg.CollisionRename} {
+		os.Remove(input + ".gz")
+		client.Collision = collision
+		if _, err := client.CompressFile(input); !errors.Is(err, pkg.ErrOutputExists) {
+			t.Fatalf("collision=%v: expected ErrOutputExists, got %v", collision, err)
+		}
+		if data, _ := os.ReadFile(input + ".gz"); string(data) != "other" {
+			t.Fatalf("collision=%v: existing output was replaced: %q", collision, data)
+		}
+		if extra := extraFiles(t, dir, "a.log", "a.log.gz"); len(extra) != 0 {
+			t.Fatalf("collision=%v: temporary files left behind: %q", collision, extra)
+		}
+	}
+}
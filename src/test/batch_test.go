@@ -0,0 +1,166 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 损坏的文件只影响自身，其余文件继续解压
+func TestDecompressBatchContinueOnError(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "1.txt")
+	bad := filepath.Join(dir, "2.txt.gz")
+	writeFile(t, good, "qzipgo")
+	writeFile(t, bad, "not gzip")
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	if _, err := client.CompressFile(good); err != nil {
+		t.Fatal(err)
+	}
+	// 解压的输出不能已经存在
+	if err := os.Remove(good); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.DecompressBatch(pkg.BatchOptions{ContinueOnError: true}, bad, good+".gz")
+	if err == nil {
+		t.Fatalf("expected an error for %s", bad)
+	}
+	if !reflect.DeepEqual(report.Succeeded, []string{good + ".gz"}) {
+		t.Fatalf("unexpected succeeded files: %q", report.Succeeded)
+	}
+	if _, ok := report.Failed[bad]; !ok || len(report.Failed) != 1 {
+		t.Fatalf("unexpected failed files: %v", report.Failed)
+	}
+	if !reflect.DeepEqual(report.Retry(), []string{bad}) {
+		t.Fatalf("unexpected retry files: %q", report.Retry())
+	}
+	if _, err := os.Stat(good); err != nil {
+		t.Fatalf("good file was not decompressed: %s", err)
+	}
+}
+
+// fail-fast 时剩余文件记录在 Remaining 中，可以只重试失败的部分
+func TestCompressBatchFailFast(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "1.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	b := filepath.Join(dir, "2.txt")
+	writeFile(t, a, "a")
+	writeFile(t, b, "b")
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	report, err := client.CompressBatch(pkg.BatchOptions{}, a, missing, b)
+	if err == nil {
+		t.Fatalf("expected an error for %s", missing)
+	}
+	if !reflect.DeepEqual(report.Succeeded, []string{a}) || !reflect.DeepEqual(report.Remaining, []string{b}) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	writeFile(t, missing, "now it exists")
+	retry, err := client.CompressBatch(pkg.BatchOptions{}, report.Retry()...)
+	if err != nil {
+		t.Fatalf("retry failed: %s", err)
+	}
+	if !reflect.DeepEqual(retry.Succeeded, []string{missing, b}) {
+		t.Fatalf("unexpected retry report: %+v", retry)
+	}
+}
+
+// 参数过长时拆分为多次调用，结果按输入顺序合并
+func TestCompressFilesSplitsLongArgumentLists(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 200; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("file-with-a-rather-long-name-%03d.txt", i))
+		writeFile(t, file, file)
+		files = append(files, file)
+	}
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Exec.Env = []string{"PATH=" + os.Getenv("PATH")}
+	client.ArgMax = 8 * 1024
+	client.Parallelism = 4
+	// 拆分只用于一次处理多个文件的调用
+	client.Atomic = false
+
+	client.DryRun = true
+	plans, err := client.CompressFiles(files...)
+	if err != nil {
+		t.Fatalf("dry-run failed: %s", err)
+	}
+	if len(plans.Plans) < 2 {
+		t.Fatalf("expected several invocations, got %d", len(plans.Plans))
+	}
+	for _, plan := range plans.Plans {
+		if size := len(strings.Join(plan.Argv, " ")); size > client.ArgMax {
+			t.Fatalf("invocation exceeds the limit: %d bytes", size)
+		}
+	}
+
+	client.DryRun = false
+	report, err := client.CompressFiles(files...)
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	if len(report.Results) != len(files) {
+		t.Fatalf("expected %d results, got %d", len(files), len(report.Results))
+	}
+	for i, result := range report.Results {
+		if result.Input != files[i] || result.BytesOut == 0 {
+			t.Fatalf("unexpected result %d: %+v", i, result)
+		}
+	}
+}
+
+// Atomic 模式下每个文件单独调用一次 qzip，DryRun 的计划与执行的命令一致
+func TestCompressFilesAtomic(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 5; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		writeFile(t, file, file)
+		files = append(files, file)
+	}
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Atomic = true
+	client.Parallelism = 2
+	client.ArgMax = 1024
+
+	client.DryRun = true
+	plans, err := client.CompressFiles(files...)
+	if err != nil || len(plans.Plans) != len(files) {
+		t.Fatalf("unexpected plans %+v: %v", plans, err)
+	}
+	for i, plan := range plans.Plans {
+		want := []string{client.Exec.QzipPath, "-k", "-o", filepath.Join(dir, ".qzipgo-*", "out"), "-r", "10", files[i]}
+		if !reflect.DeepEqual(plan.Argv, want) || !reflect.DeepEqual(plan.Outputs, []string{files[i] + ".gz"}) {
+			t.Fatalf("unexpected plan %d: %+v", i, plan)
+		}
+	}
+
+	client.DryRun = false
+	report, err := client.CompressFiles(files...)
+	if err != nil || len(report.Results) != len(files) {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+	for i, result := range report.Results {
+		if result.Input != files[i] || result.Output != files[i]+".gz" || result.BytesOut == 0 {
+			t.Fatalf("unexpected result %d: %+v", i, result)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2*len(files) {
+		t.Fatalf("temporary files left behind: %d entries", len(entries))
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 输出文件已存在时按策略失败、覆盖、跳过或重命名，并报告每个文件的处理结果
+func TestCollisionPolicies(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "a.log")
+		output := input + ".gz"
+		writeFile(t, input, "new content")
+		writeFile(t, output, "old output")
+
+		client := pkg.NewClient()
+		client.Exec.QzipPath = fakeQzip(t)
+		client.Atomic = atomic
+
+		if _, err := client.CompressFile(input); !errors.Is(err, pkg.ErrOutputExists) {
+			t.Fatalf("atomic=%v: expected ErrOutputExists, got %v", atomic, err)
+		}
+
+		client.Collision = pkg.CollisionSkip
+		report, err := client.CompressFile(input)
+		if err != nil {
+			t.Fatalf("atomic=%v: skip failed: %s", atomic, err)
+		}
+		if report.Results[0].Decision != pkg.DecisionSkipped {
+			t.Fatalf("atomic=%v: unexpected result: %+v", atomic, report.Results[0])
+		}
+		if data, _ := os.ReadFile(output); string(data) != "old output" {
+			t.Fatalf("atomic=%v: skipped output was modified", atomic)
+		}
+
+		client.Collision = pkg.CollisionRename
+		report, err = client.CompressFile(input)
+		if err != nil {
+			t.Fatalf("atomic=%v: rename failed: %s", atomic, err)
+		}
+		renamed := filepath.Join(dir, "a.log.1.gz")
+		want := pkg.Collision{Path: output, Output: renamed, Decision: pkg.DecisionRenamed}
+		if len(report.Collisions) != 1 || report.Collisions[0] != want || report.Results[0].Output != renamed {
+			t.Fatalf("atomic=%v: unexpected report: %+v", atomic, report)
+		}
+
+		client.Collision = pkg.CollisionOverwrite
+		report, err = client.CompressFile(input)
+		if err != nil {
+			t.Fatalf("atomic=%v: overwrite failed: %s", atomic, err)
+		}
+		if report.Results[0].Decision != pkg.DecisionOverwritten {
+			t.Fatalf("atomic=%v: unexpected result: %+v", atomic, report.Results[0])
+		}
+		if data, _ := os.ReadFile(output); string(data) == "old output" {
+			t.Fatalf("atomic=%v: output was not overwritten", atomic)
+		}
+	}
+}
+
+// tar 解压时在移动文件的步骤按策略处理已存在的文件
+func TestCollisionPolicyTarExtract(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data", "a.log"), "archived")
+	client := pkg.NewClient()
+	client.Atomic = true
+	client.Exec.QzipPath = fakeQzip(t)
+	archive := filepath.Join(dir, "data.tgz")
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+		t.Fatalf("tar failed: %s", err)
+	}
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); !errors.Is(err, pkg.ErrOutputExists) {
+		t.Fatalf("expected ErrOutputExists for the archive, got %v", err)
+	}
+
+	restored := filepath.Join(dir, "restored")
+	writeFile(t, filepath.Join(restored, "data", "a.log"), "existing")
+	if _, err := client.DecompressDictoryByTar(archive, restored); !errors.Is(err, pkg.ErrOutputExists) {
+		t.Fatalf("expected ErrOutputExists, got %v", err)
+	}
+
+	client.Collision = pkg.CollisionRename
+	report, err := client.DecompressDictoryByTar(archive, restored)
+	if err != nil {
+		t.Fatalf("untar failed: %s", err)
+	}
+	existing := filepath.Join(restored, "data", "a.log")
+	renamed := filepath.Join(restored, "data", "a.1.log")
+	want := pkg.Collision{Path: existing, Output: renamed, Decision: pkg.DecisionRenamed}
+	if len(report.Collisions) != 1 || report.Collisions[0] != want {
+		t.Fatalf("unexpected collisions: %+v", report.Collisions)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "existing" {
+		t.Fatalf("existing file was modified")
+	}
+	if data, _ := os.ReadFile(renamed); string(data) != "archived" {
+		t.Fatalf("unexpected renamed file: %q", data)
+	}
+}
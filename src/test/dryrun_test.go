@@ -0,0 +1,114 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// dry-run 模式下返回执行计划，不执行 qzip
+func TestDryRunDecompressFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "1.txt.gz")
+	b := filepath.Join(dir, "2.txt.gz")
+	for _, f := range []string{a, b} {
+		// 只有 gzip 的 magic，解压时根据 magic 识别格式
+		if err := os.WriteFile(f, []byte("\x1f\x8b\x08not really gzip"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := pkg.NewClient()
+	client.DryRun = true
+	client.Exec.QzipPath = "/opt/qatzip/bin/qzip"
+	report, err := client.DecompressFiles(a, b)
+	if err != nil {
+		t.Fatalf("dry-run failed: %s", err)
+	}
+	if len(report.Plans) != 1 {
+		t.Fatalf("expected one plan, got %d", len(report.Plans))
+	}
+	plan := report.Plans[0]
+	wantArgv := []string{"/opt/qatzip/bin/qzip", "-d", "-r", "10", a, b}
+	if !reflect.DeepEqual(plan.Argv, wantArgv) {
+		t.Fatalf("unexpected argv: %q", plan.Argv)
+	}
+	wantOutputs := []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")}
+	if !reflect.DeepEqual(plan.Outputs, wantOutputs) {
+		t.Fatalf("unexpected outputs: %q", plan.Outputs)
+	}
+	if !reflect.DeepEqual(plan.Deletes, []string{a, b}) {
+		t.Fatalf("unexpected deletes: %q", plan.Deletes)
+	}
+	// 源文件不能被删除
+	if _, err := os.Stat(a); err != nil {
+		t.Fatalf("source removed in dry-run: %s", err)
+	}
+}
+
+// 目录打包的执行计划：工作目录为数据的父目录
+func TestDryRunCompressDictoryByTar(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if err := os.MkdirAll(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	client := pkg.NewClient()
+	client.DryRun = true
+	report, err := client.CompressDictoryByTar(data, "")
+	if err != nil {
+		t.Fatalf("dry-run failed: %s", err)
+	}
+	plan := report.Plans[0]
+	if plan.Dir != dir {
+		t.Fatalf("unexpected working directory: %s", plan.Dir)
+	}
+	if !reflect.DeepEqual(plan.Outputs, []string{data + ".tgz"}) {
+		t.Fatalf("unexpected outputs: %q", plan.Outputs)
+	}
+	if _, err := os.Stat(data + ".tgz"); !os.IsNotExist(err) {
+		t.Fatalf("archive created in dry-run")
+	}
+}
+
+// 计划包含执行时按 Collision 与 SafeDelete 添加的选项
+func TestDryRunOptions(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.txt")
+	writeFile(t, input, "a")
+	writeFile(t, input+".gz", "existing")
+
+	client := pkg.NewClient()
+	client.DryRun = true
+	client.Exec.QzipPath = "/opt/qatzip/bin/qzip"
+	if _, err := client.CompressFiles(input); !errors.Is(err, pkg.ErrOutputExists) {
+		t.Fatalf("expected ErrOutputExists, got %v", err)
+	}
+	client.Collision = pkg.CollisionOverwrite
+	report, err := client.CompressFiles(input)
+	if err != nil {
+		t.Fatalf("dry-run failed: %s", err)
+	}
+	if want := []string{"/opt/qatzip/bin/qzip", "-f", "-k", "-r", "10", input}; !reflect.DeepEqual(report.Plans[0].Argv, want) {
+		t.Fatalf("unexpected argv: %q", report.Plans[0].Argv)
+	}
+
+	// 安全删除由 qzip 保留源文件，校验后再删除
+	archive := filepath.Join(dir, "b.txt.gz")
+	os.WriteFile(archive, []byte("\x1f\x8b\x08not really gzip"), 0o644)
+	client.SafeDelete = &pkg.SafeDeleteOptions{}
+	report, err = client.DecompressFiles(archive)
+	if err != nil {
+		t.Fatalf("dry-run failed: %s", err)
+	}
+	plan := report.Plans[0]
+	if !slices.Contains(plan.Argv, "-k") || !reflect.DeepEqual(plan.Deletes, []string{archive}) {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
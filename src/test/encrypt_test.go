@@ -0,0 +1,210 @@
+package test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/crypt"
+)
+
+// 生成包含给定密钥 ID 的密钥文件，第一个密钥用于加密
+func writeKeyFile(t *testing.T, path string, ids ...string) *crypt.FileKeyProvider {
+	t.Helper()
+	var content strings.Builder
+	content.WriteString("# test keys\n")
+	for _, id := range ids {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(id + " " + hex.EncodeToString(key) + "\n")
+	}
+	writeFile(t, path, content.String())
+	keys, err := crypt.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// 使用给定的块大小加密
+func encrypt(t *testing.T, keys crypt.KeyProvider, chunkSize int, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := crypt.NewWriter(&buf, keys, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 解密全部数据
+func decrypt(keys crypt.KeyProvider, data []byte) ([]byte, error) {
+	r, err := crypt.NewReader(bytes.NewReader(data), keys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	keys := writeKeyFile(t, filepath.Join(t.TempDir(), "keys"), "k1")
+	data := make([]byte, 1000)
+	rand.Read(data)
+	// 空数据、不足一个块、正好整数个块、多个块
+	for _, size := range []int{0, 10, 256, 1000} {
+		sealed := encrypt(t, keys, 128, data[:size])
+		if !bytes.HasPrefix(sealed, []byte(crypt.Magic)) {
+			t.Fatalf("size %d: missing magic", size)
+		}
+		got, err := decrypt(keys, sealed)
+		if err != nil || !bytes.Equal(got, data[:size]) {
+			t.Fatalf("size %d: round trip failed: %v", size, err)
+		}
+	}
+}
+
+func TestCryptTampered(t *testing.T) {
+	dir := t.TempDir()
+	keys := writeKeyFile(t, filepath.Join(dir, "keys"), "k1", "k0")
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	sealed := encrypt(t, keys, 128, data)
+
+	// 修改密文
+	modified := append([]byte(nil), sealed...)
+	modified[len(modified)/2] ^= 1
+	if _, err := decrypt(keys, modified); !errors.Is(err, crypt.ErrAuth) {
+		t.Fatalf("expected ErrAuth for modified data, got %v", err)
+	}
+	// 在块边界截断：header(4+1+4+1+2+8) 之后的第一个完整块
+	header := 4 + 1 + 4 + 1 + len("k1") + 8
+	if _, err := decrypt(keys, sealed[:header+128+16]); !errors.Is(err, crypt.ErrAuth) {
+		t.Fatalf("expected ErrAuth for truncated data, got %v", err)
+	}
+	// 修改头部
+	modified = append([]byte(nil), sealed...)
+	modified[6] ^= 1
+	if _, err := decrypt(keys, modified); err == nil {
+		t.Fatalf("expected an error for a modified header")
+	}
+	// 不持有加密使用的密钥
+	other := writeKeyFile(t, filepath.Join(dir, "other"), "k0")
+	if _, err := decrypt(other, sealed); !errors.Is(err, crypt.ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	// 密钥 ID 相同但密钥不同
+	wrong := writeKeyFile(t, filepath.Join(dir, "wrong"), "k1")
+	if _, err := decrypt(wrong, sealed); !errors.Is(err, crypt.ErrAuth) {
+		t.Fatalf("expected ErrAuth for a wrong key, got %v", err)
+	}
+	if _, err := decrypt(keys, []byte("not encrypted")); !errors.Is(err, crypt.ErrFormat) {
+		t.Fatalf("expected ErrFormat, got %v", err)
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys")
+	writeFile(t, path, "# comment\n\nnew AAAAAAAAAAAAAAAAAAAAAA==\nold "+strings.Repeat("ab", 32)+"\n")
+	keys, err := crypt.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, key, err := keys.EncryptionKey(); err != nil || id != "new" || len(key) != 16 {
+		t.Fatalf("unexpected encryption key %q (%d bytes): %v", id, len(key), err)
+	}
+	if key, err := keys.DecryptionKey("old"); err != nil || len(key) != 32 {
+		t.Fatalf("unexpected decryption key (%d bytes): %v", len(key), err)
+	}
+	for _, content := range []string{"k1 abcd\n", "k1\n", "k1 " + strings.Repeat("ab", 16) + "\nk1 " + strings.Repeat("cd", 16) + "\n", "# empty\n"} {
+		writeFile(t, path, content)
+		if _, err := crypt.LoadKeyFile(path); err == nil {
+			t.Fatalf("expected an error for %q", content)
+		}
+	}
+}
+
+// 加密的 tar 归档：写入 .enc 文件，解压前校验整个归档
+func TestEncryptedTar(t *testing.T) {
+	dir := t.TempDir()
+	keys := writeKeyFile(t, filepath.Join(dir, "keys"), "k1")
+	writeFile(t, filepath.Join(dir, "data", "a.txt"), "plaintext content")
+	writeFile(t, filepath.Join(dir, "data", "sub", "b.txt"), "b")
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Encryption = &pkg.EncryptionOptions{Keys: keys, ChunkSize: 64}
+	report, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), filepath.Join(dir, "data.tgz"))
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	archive := filepath.Join(dir, "data.tgz.enc")
+	if len(report.Results) != 1 || report.Results[0].Output != archive || report.Results[0].BytesOut == 0 {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+	if format, err := pkg.Identify(archive); err != nil || format != pkg.FormatEncrypted {
+		t.Fatalf("unexpected format %s: %v", format, err)
+	}
+	if _, err := client.Verify(pkg.BatchOptions{}, archive); err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+
+	restored := filepath.Join(dir, "restored")
+	os.MkdirAll(restored, 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, restored); err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(restored, "data", "sub", "b.txt")); err != nil || string(data) != "b" {
+		t.Fatalf("unexpected content %q: %v", data, err)
+	}
+
+	// 没有密钥时无法解压
+	if _, err := pkg.NewClient().DecompressDictoryByTar(archive, restored); err == nil {
+		t.Fatalf("expected an error without keys")
+	}
+	// 被修改的归档不会解压出任何文件
+	data, _ := os.ReadFile(archive)
+	data[len(data)-20] ^= 1
+	writeFile(t, archive, string(data))
+	tampered := filepath.Join(dir, "tampered")
+	os.MkdirAll(tampered, 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, tampered); !errors.Is(err, crypt.ErrAuth) {
+		t.Fatalf("expected ErrAuth, got %v", err)
+	}
+	if entries, _ := os.ReadDir(tampered); len(entries) != 0 {
+		t.Fatalf("tampered archive extracted %d entries", len(entries))
+	}
+}
+
+// 加密文件中解密后的数据损坏时，不报告在加密文件中没有意义的偏移
+func TestVerifyEncryptedCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	keys := writeKeyFile(t, filepath.Join(dir, "keys"), "k1")
+	corrupt := gzipBytes(t, []byte("content"))
+	corrupt[len(corrupt)-8] ^= 0xff
+	file := filepath.Join(dir, "data.gz.enc")
+	os.WriteFile(file, encrypt(t, keys, 64, corrupt), 0o644)
+
+	client := pkg.NewClient()
+	client.Software = true
+	client.Encryption = &pkg.EncryptionOptions{Keys: keys}
+	_, err := client.Verify(pkg.BatchOptions{}, file)
+	var integrity *pkg.IntegrityError
+	if !errors.As(err, &integrity) || integrity.Offset != -1 {
+		t.Fatalf("unexpected verify error %v", err)
+	}
+}
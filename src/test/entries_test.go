@@ -0,0 +1,193 @@
+package test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 按给定条目生成 tar 归档
+func tarEntries(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestListAndExtractEntries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data", "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "data", "logs", "x.log"), "xx")
+	writeFile(t, filepath.Join(dir, "data", "logs", "y.log"), "yyy")
+	os.Symlink("a.txt", filepath.Join(dir, "data", "link"))
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	archive := filepath.Join(dir, "data.tgz")
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+
+	entries, err := client.ListArchive(archive)
+	if err != nil {
+		t.Fatalf("list failed: %s", err)
+	}
+	sizes := make(map[string]int64)
+	for _, entry := range entries {
+		sizes[entry.Name] = entry.Size
+		if entry.Name == "data/link" && (entry.Mode&fs.ModeSymlink == 0 || entry.Linkname != "a.txt") {
+			t.Fatalf("unexpected link entry %+v", entry)
+		}
+	}
+	if len(entries) != 6 || sizes["data/logs/y.log"] != 3 || sizes["data/a.txt"] != 1 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	// 目录模式解压整个目录，只写入匹配的条目
+	out := filepath.Join(dir, "out")
+	report, err := client.ExtractEntries(archive, []string{"data/logs", "link"}, out)
+	if err != nil {
+		t.Fatalf("extract failed: %s", err)
+	}
+	if report.Results[0].BytesOut != 5 {
+		t.Fatalf("unexpected result %+v", report.Results[0])
+	}
+	var extracted []string
+	filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(out, p)
+		extracted = append(extracted, filepath.ToSlash(rel))
+		return nil
+	})
+	if want := []string{".", "data", "data/link", "data/logs", "data/logs/x.log", "data/logs/y.log"}; !slices.Equal(extracted, want) {
+		t.Fatalf("unexpected files %v", extracted)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "data", "logs", "y.log")); string(data) != "yyy" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	// 已存在的文件按 Collision 处理
+	if _, err := client.ExtractEntries(archive, []string{"*.log"}, out); !errors.Is(err, pkg.ErrOutputExists) {
+		t.Fatalf("expected ErrOutputExists, got %v", err)
+	}
+	client.Collision = pkg.CollisionSkip
+	if report, err := client.ExtractEntries(archive, []string{"*.log"}, out); err != nil || len(report.Collisions) != 2 {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+	if _, err := client.ExtractEntries(archive, []string{"missing"}, out); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := client.ExtractEntries(archive, []string{"[x"}, out); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+
+	// Software 模式与未压缩的 tar 不需要 qzip
+	software := pkg.NewClient()
+	software.Exec.QzipPath = "/nonexistent/qzip"
+	software.Software = true
+	if entries, err := software.ListArchive(archive); err != nil || len(entries) != 6 {
+		t.Fatalf("software list: %d entries: %v", len(entries), err)
+	}
+	plain := filepath.Join(dir, "plain.tar")
+	os.WriteFile(plain, tarEntries(t, &tar.Header{Name: "p.txt", Mode: 0o600, Size: 4}), 0o644)
+	if _, err := software.ExtractEntries(plain, []string{"p.txt"}, out); err != nil {
+		t.Fatalf("extract plain tar: %s", err)
+	}
+	if info, err := os.Stat(filepath.Join(out, "p.txt")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected file %v: %v", info, err)
+	}
+}
+
+// 拒绝写入目标目录之外的条目
+func TestExtractEntriesUnsafe(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	os.MkdirAll(outside, 0o755)
+	client := pkg.NewClient()
+	client.Software = true
+	cases := map[string][]*tar.Header{
+		"parent": {{Name: "../evil", Mode: 0o644, Size: 1}},
+		"abs":    {{Name: "/tmp/evil", Mode: 0o644, Size: 1}},
+		"link": {
+			{Name: "lnk", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "lnk/evil", Mode: 0o644, Size: 1},
+		},
+		"hardlink": {{Name: "hard", Typeflag: tar.TypeLink, Linkname: "../outside/x"}},
+	}
+	for name, headers := range cases {
+		archive := filepath.Join(dir, name+".tgz")
+		os.WriteFile(archive, gzipBytes(t, tarEntries(t, headers...)), 0o644)
+		out := filepath.Join(dir, "out-"+name)
+		if _, err := client.ExtractEntries(archive, []string{"*"}, out); !errors.Is(err, pkg.ErrUnsafeEntry) {
+			t.Fatalf("%s: expected ErrUnsafeEntry, got %v", name, err)
+		}
+	}
+
+	// 目录条目不能替换为 dst 中已有的符号链接
+	out := filepath.Join(dir, "out-dir")
+	os.MkdirAll(out, 0o755)
+	os.Symlink(outside, filepath.Join(out, "d"))
+	archive := filepath.Join(dir, "dir.tgz")
+	os.WriteFile(archive, gzipBytes(t, tarEntries(t, &tar.Header{Name: "d/", Typeflag: tar.TypeDir, Mode: 0o777})), 0o644)
+	if _, err := client.ExtractEntries(archive, []string{"d"}, out); !errors.Is(err, pkg.ErrUnsafeEntry) {
+		t.Fatalf("dir: expected ErrUnsafeEntry, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("wrote %d entries outside the destination", len(entries))
+	}
+}
+
+// 硬链接只能与其目标一起解压，目标没有选中时返回指出目标的错误
+func TestExtractEntriesHardLink(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "links.tgz")
+	os.WriteFile(archive, gzipBytes(t, tarEntries(t,
+		&tar.Header{Name: "data/a.txt", Mode: 0o644, Size: 3},
+		&tar.Header{Name: "data/b.txt", Typeflag: tar.TypeLink, Linkname: "data/a.txt"},
+	)), 0o644)
+	client := pkg.NewClient()
+	client.Software = true
+
+	out := filepath.Join(dir, "out")
+	_, err := client.ExtractEntries(archive, []string{"b.txt"}, out)
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "data/a.txt") {
+		t.Fatalf("expected an error naming the link target, got %v", err)
+	}
+	if _, err := client.ExtractEntries(archive, []string{"data"}, out); err != nil {
+		t.Fatalf("extract failed: %s", err)
+	}
+	a, _ := os.Stat(filepath.Join(out, "data", "a.txt"))
+	b, err := os.Stat(filepath.Join(out, "data", "b.txt"))
+	if err != nil || !os.SameFile(a, b) {
+		t.Fatalf("b.txt is not a hard link of a.txt: %v", err)
+	}
+
+	// 目标按 Collision 重命名时链接到重命名后的文件
+	client.Collision = pkg.CollisionRename
+	report, err := client.ExtractEntries(archive, []string{"data"}, out)
+	if err != nil || len(report.Collisions) != 2 {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+	renamed, _ := os.Stat(report.Collisions[0].Output)
+	link, _ := os.Stat(report.Collisions[1].Output)
+	if !os.SameFile(renamed, link) || os.SameFile(a, link) {
+		t.Fatalf("link does not follow the renamed target: %+v", report.Collisions)
+	}
+}
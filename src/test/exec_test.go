@@ -0,0 +1,120 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 显式环境变量会被 ICP_ROOT/QZ_ROOT/LD_LIBRARY_PATH 配置覆盖
+func TestExecConfigEnviron(t *testing.T) {
+	cfg := internal.GetDefaultExecConfig()
+	cfg.QzipPath = "/opt/qatzip/bin/qzip"
+	cfg.Env = []string{"PATH=/usr/bin", "QZ_ROOT=/old", "QZ_ROOT=/older"}
+	cfg.QzRoot = "/opt/qatzip"
+	cfg.IcpRoot = "/opt/icp"
+	cfg.LdLibraryPath = "/opt/qatzip/lib"
+
+	env := cfg.Environ()
+	want := []string{"PATH=/usr/bin", "ICP_ROOT=/opt/icp", "QZ_ROOT=/opt/qatzip", "LD_LIBRARY_PATH=/opt/qatzip/lib"}
+	if strings.Join(env, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected environment: %q", env)
+	}
+
+	cmd := internal.GetDefaultQzipCommand()
+	cmd.Exec = cfg
+	cmd.InputFile = []string{"/tmp/test.txt"}
+	qzipCmd := cmd.BuildQzipCommand()
+	if qzipCmd.Path != cfg.QzipPath {
+		t.Fatalf("unexpected qzip path: %s", qzipCmd.Path)
+	}
+	if len(qzipCmd.Env) != len(want) {
+		t.Fatalf("unexpected child environment: %q", qzipCmd.Env)
+	}
+}
+
+// 包外可以使用 pkg.ExecConfig 构造执行配置
+func TestExecConfigLiteral(t *testing.T) {
+	client := pkg.NewClient()
+	client.Exec = pkg.ExecConfig{QzipPath: "/opt/qatzip/bin/qzip", Env: []string{"PATH=/usr/bin"}}
+
+	cmd := internal.GetDefaultQzipCommand()
+	cmd.Exec = client.Exec
+	cmd.InputFile = []string{"/tmp/test.txt"}
+	qzipCmd := cmd.BuildQzipCommand()
+	if qzipCmd.Path != "/opt/qatzip/bin/qzip" || strings.Join(qzipCmd.Env, "\n") != "PATH=/usr/bin" {
+		t.Fatalf("unexpected qzip command %s: %q", qzipCmd, qzipCmd.Env)
+	}
+}
+
+// tar 的 -I 选项使用配置的 qzip 路径
+func TestTarUsesConfiguredQzip(t *testing.T) {
+	cmd := internal.GetDefaultTarCommand()
+	cmd.Exec.QzipPath = "/opt/qatzip/bin/qzip"
+	cmd.Exec.TarPath = "/usr/local/bin/tar"
+	cmd.Compression = false
+	cmd.ArchiveFile = "/tmp/test.tgz"
+
+	tarCmd := cmd.BuildTarCommand()
+	if tarCmd.Path != "/usr/local/bin/tar" {
+		t.Fatalf("unexpected tar path: %s", tarCmd.Path)
+	}
+	if !strings.Contains(tarCmd.String(), "-I /opt/qatzip/bin/qzip -d") {
+		t.Fatalf("unexpected tar command: %s", tarCmd.String())
+	}
+}
+
+// 注入的 Logger 输出结构化字段：命令、文件、耗时
+func TestClientLogger(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "1.txt")
+	writeFile(t, input, "qzipgo")
+
+	var buf bytes.Buffer
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	if _, err := client.CompressFile(input); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+
+	var finished map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line is not JSON: %s", line)
+		}
+		if record["msg"] == "command finished" {
+			finished = record
+		}
+	}
+	if finished == nil {
+		t.Fatalf("missing command finished record: %s", buf.String())
+	}
+	for _, key := range []string{"command", "file", "duration"} {
+		if _, ok := finished[key]; !ok {
+			t.Fatalf("missing %s field: %v", key, finished)
+		}
+	}
+
+	// 通过管道读写数据的 qzip 记录相同格式的命令行
+	buf.Reset()
+	if _, err := client.CompressRootsByTar(filepath.Join(t.TempDir(), "bundle"), pkg.TarRoot{Source: input}); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	var executing map[string]any
+	if err := json.Unmarshal([]byte(strings.SplitN(buf.String(), "\n", 2)[0]), &executing); err != nil {
+		t.Fatal(err)
+	}
+	if executing["msg"] != "executing command" || !strings.HasPrefix(executing["command"].(string), client.Exec.QzipPath+" ") {
+		t.Fatalf("unexpected record: %v", executing)
+	}
+	if _, ok := executing["dir"]; !ok {
+		t.Fatalf("missing dir field: %v", executing)
+	}
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/gzipext"
+)
+
+// 按 qzip gzipext 格式生成数据：每个块一个带有 QZ 扩展字段的 gzip 成员
+func gzipExtBytes(t *testing.T, data []byte, chunk int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		var payload bytes.Buffer
+		fw, _ := flate.NewWriter(&payload, flate.BestSpeed)
+		fw.Write(data[:n])
+		fw.Close()
+		header := []byte{0x1f, 0x8b, 8, 0x04, 0, 0, 0, 0, 0, 3, 12, 0, 'Q', 'Z', 8, 0}
+		header = binary.LittleEndian.AppendUint32(header, uint32(n))
+		header = binary.LittleEndian.AppendUint32(header, uint32(payload.Len()))
+		out.Write(header)
+		out.Write(payload.Bytes())
+		trailer := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(data[:n]))
+		trailer = binary.LittleEndian.AppendUint32(trailer, uint32(n))
+		out.Write(trailer)
+		data = data[n:]
+	}
+	return out.Bytes()
+}
+
+// 并行解压多个块，按顺序输出，并校验每个块
+func TestGzipExtDecompress(t *testing.T) {
+	data := []byte(strings.Repeat("qzipgo gzipext block data ", 5000))
+	stream := gzipExtBytes(t, data, 4096)
+	// 末尾追加一个普通 gzip 成员
+	stream = append(stream, gzipBytes(t, []byte("tail"))...)
+	want := append(append([]byte(nil), data...), "tail"...)
+
+	var out bytes.Buffer
+	n, err := gzipext.Decompress(&out, bytes.NewReader(stream), 4)
+	if err != nil || n != int64(len(want)) || !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("unexpected output: %d bytes, %v", n, err)
+	}
+
+	members, err := gzipext.Scan(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := (len(data) + 4095) / 4096
+	if len(members) != blocks+1 || !members[0].QZ || members[0].ChunkSize != 4096 || members[blocks].QZ {
+		t.Fatalf("unexpected members: %d %+v", len(members), members[0])
+	}
+
+	r := gzipext.NewReader(bytes.NewReader(stream), 2)
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("unexpected reader output: %v", err)
+	}
+
+	// 破坏第二个块的 CRC32
+	corrupt := append([]byte(nil), stream...)
+	second := members[1]
+	corrupt[second.Offset+second.HeaderSize+int64(second.BlockSize)] ^= 0xff
+	if _, err := gzipext.Decompress(io.Discard, bytes.NewReader(corrupt), 4); !errors.Is(err, gzipext.ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+	if _, err := gzipext.Decompress(io.Discard, bytes.NewReader(stream[:len(stream)/2]), 4); err == nil {
+		t.Fatalf("expected an error for a truncated stream")
+	}
+}
+
+// 普通 gzip 成员与 QZ 块交替时按顺序输出；损坏的头部与压缩炸弹在分配大块内存之前被拒绝
+func TestGzipExtLimits(t *testing.T) {
+	plain := bytes.Repeat([]byte("plain member "), 10000)
+	stream := append(gzipExtBytes(t, []byte("first"), 4096), gzipBytes(t, plain)...)
+	stream = append(stream, gzipExtBytes(t, []byte("last"), 4096)...)
+	var out bytes.Buffer
+	if _, err := gzipext.Decompress(&out, bytes.NewReader(stream), 4); err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte("first"), plain...), "last"...); !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("unexpected output order")
+	}
+	// 普通成员的 CRC32 在写出时计算
+	corrupt := gzipBytes(t, plain)
+	corrupt[len(corrupt)-8] ^= 0xff
+	if _, err := gzipext.Decompress(io.Discard, bytes.NewReader(corrupt), 1); !errors.Is(err, gzipext.ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+
+	// ChunkSize 超过 QAT 上限
+	huge := gzipExtBytes(t, []byte("x"), 4096)
+	binary.LittleEndian.PutUint32(huge[16:20], 0xffffffff)
+	if _, err := gzipext.Decompress(io.Discard, bytes.NewReader(huge), 1); !errors.Is(err, gzipext.ErrHeader) {
+		t.Fatalf("expected ErrHeader, got %v", err)
+	}
+	// 声明 16 字节的块解压出 1MB
+	bomb := gzipExtBytes(t, make([]byte, 1<<20), 1<<20)
+	binary.LittleEndian.PutUint32(bomb[16:20], 16)
+	if _, err := gzipext.Decompress(io.Discard, bytes.NewReader(bomb), 1); !errors.Is(err, gzipext.ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+}
+
+// Software 模式下不需要 qzip 即可解压 gzipext 文件
+func TestSoftwareDecompress(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte(strings.Repeat("analytics ", 1000))
+	file := filepath.Join(dir, "data.log.gz")
+	if err := os.WriteFile(file, gzipExtBytes(t, data, 1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Exec.QzipPath = filepath.Join(dir, "no-qzip")
+	client.Software = true
+	for _, atomic := range []bool{true, false} {
+		client.Atomic = atomic
+		report, err := client.DecompressFile(file)
+		if err != nil {
+			t.Fatalf("atomic=%v: decompress failed: %s", atomic, err)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "data.log"))
+		if err != nil || !bytes.Equal(got, data) || report.Results[0].BytesOut != int64(len(data)) {
+			t.Fatalf("atomic=%v: unexpected output: %v", atomic, err)
+		}
+		os.Remove(filepath.Join(dir, "data.log"))
+	}
+}
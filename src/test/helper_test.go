@@ -0,0 +1,61 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 使用 gzip 模拟 qzip 的脚本，支持 -d/-k/-o 选项，-A lz4/lz4s 时使用对应的后缀，输出与 qzip 相同格式的统计信息；
+// 没有输入文件时从标准输入读取并写入标准输出（tar -I 使用的方式）
+const fakeQzipScript = `#!/bin/sh
+decompress=0; keep=0; out=""; suffix=.gz
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-d) decompress=1 ;;
+	-k) keep=1 ;;
+	-o) shift; out="$1" ;;
+	-A) shift; case "$1" in lz4) suffix=.lz4 ;; lz4s) suffix=.lz4s ;; esac ;;
+	-O|-L|-r|-P) shift ;;
+	-R|-f) ;;
+	*) break ;;
+	esac
+	shift
+done
+if [ $# -eq 0 ]; then
+	if [ $decompress = 1 ]; then exec gzip -dc; else exec gzip -c; fi
+fi
+for f in "$@"; do
+	if [ $decompress = 1 ]; then
+		dst="${f%.gz}"; dst="${dst%.lz4}"; dst="${out:-${dst%.lz4s}}"
+		gzip -dc "$f" > "$dst" || exit 1
+	else
+		dst="${out:-$f}$suffix"
+		gzip -c "$f" > "$dst" || exit 1
+	fi
+	[ $keep = 1 ] || rm -f "$f"
+	echo "Time taken:     1.500 ms"
+	echo "Throughput:     8.000 Mbit/s"
+done
+`
+
+// 在临时目录中生成模拟的 qzip，返回其绝对路径
+func fakeQzip(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "qzip")
+	if err := os.WriteFile(path, []byte(fakeQzipScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 写入测试文件
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// gzip 压缩的数据
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 只包含一个文件的 tar 归档
+func tarBytes(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 根据 magic 识别 gzip、gzipext、LZ4、LZ4s 与 tar
+func TestIdentify(t *testing.T) {
+	dir := t.TempDir()
+	// gzip 头部：FEXTRA，XLEN=12，"QZ" 子字段，LEN=8，后接原始大小与压缩大小
+	gzipExt := []byte{0x1f, 0x8b, 8, 0x04, 0, 0, 0, 0, 0, 3, 12, 0, 'Q', 'Z', 8, 0, 5, 0, 0, 0, 7, 0, 0, 0}
+	files := map[string]struct {
+		data   []byte
+		format pkg.Format
+	}{
+		"a.gz":   {gzipBytes(t, []byte("a")), pkg.FormatGzip},
+		"b.gz":   {gzipExt, pkg.FormatGzipExt},
+		"c.lz4":  {[]byte{0x04, 0x22, 0x4d, 0x18, 0x64}, pkg.FormatLz4},
+		"d.lz4s": {[]byte{0x04, 0x22, 0x4d, 0x18, 0x64}, pkg.FormatLz4s},
+		"e.tar":  {tarBytes(t, "e.txt", "e"), pkg.FormatTar},
+		"f.gz":   {[]byte("plain text"), pkg.FormatUnknown},
+	}
+	for name, file := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, file.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if format, err := pkg.Identify(path); err != nil || format != file.format {
+			t.Fatalf("%s: got %s %v, want %s", name, format, err, file.format)
+		}
+	}
+	if format, _ := pkg.IdentifyReader(bytes.NewReader(files["d.lz4s"].data)); format != pkg.FormatLz4 {
+		t.Fatalf("LZ4s stream without a name should be reported as lz4, got %s", format)
+	}
+
+	client := pkg.NewClient()
+	client.DryRun = true
+	client.Exec.QzipPath = "qzip"
+	if _, err := client.DecompressFile(filepath.Join(dir, "f.gz")); !errors.Is(err, pkg.ErrNotCompressed) {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+	report, err := client.DecompressFiles(filepath.Join(dir, "c.lz4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"qzip", "-d", "-A", "lz4", "-O", "lz4", "-r", "10", filepath.Join(dir, "c.lz4")}
+	if !reflect.DeepEqual(report.Plans[0].Argv, want) {
+		t.Fatalf("unexpected argv: %q", report.Plans[0].Argv)
+	}
+}
+
+// tar 解压根据内容而不是后缀判断归档是否有效
+func TestDecompressDictoryByTarChecksContent(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.bin")
+	if err := os.WriteFile(archive, gzipBytes(t, tarBytes(t, "a.txt", "a")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notTar := filepath.Join(dir, "not-tar.tgz")
+	if err := os.WriteFile(notTar, gzipBytes(t, []byte("plain")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.DryRun = true
+	if _, err := client.DecompressDictoryByTar(archive, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := client.DecompressDictoryByTar(notTar, dir); err == nil {
+		t.Fatalf("expected an error for a gzip file that is not a tar archive")
+	}
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 第二次运行只压缩新增或变化的文件，并删除源文件已经消失的压缩文件
+func TestCompressIncremental(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.log")
+	b := filepath.Join(root, "sub", "b.log")
+	writeFile(t, a, "a")
+	writeFile(t, b, "b")
+	opts := pkg.IncrementalOptions{
+		StateFile:   filepath.Join(root, ".qzipgo-state.json"),
+		RemoveStale: true,
+		Walk:        pkg.WalkOptions{IncludeHidden: true},
+	}
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	report, err := client.CompressIncremental(root, opts)
+	if err != nil {
+		t.Fatalf("first run failed: %s", err)
+	}
+	if len(report.Results) != 2 || len(report.Unchanged) != 0 {
+		t.Fatalf("unexpected first report: %+v", report)
+	}
+
+	// 修改 a、新增 c、删除 b；只 touch 不修改内容的文件通过哈希判断为未变化
+	c := filepath.Join(root, "c.log")
+	writeFile(t, a, "a changed")
+	writeFile(t, c, "c")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	d := filepath.Join(root, "d.log")
+	writeFile(t, d, "d")
+	if _, err := client.CompressIncremental(root, opts); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(d, later, later); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, c, "c changed")
+
+	report, err = client.CompressIncremental(root, opts)
+	if err != nil {
+		t.Fatalf("third run failed: %s", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Input != c {
+		t.Fatalf("unexpected compressed files: %+v", report.Results)
+	}
+	if !reflect.DeepEqual(report.Unchanged, []string{a, d}) {
+		t.Fatalf("unexpected unchanged files: %q", report.Unchanged)
+	}
+	if _, err := os.Stat(b + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("stale output was not removed")
+	}
+
+	// 压缩失败时保留上一次的压缩文件，下一次运行时重新压缩
+	writeFile(t, c, "c changed again")
+	failing := pkg.NewClient()
+	failing.Exec.QzipPath = "/nonexistent/qzip"
+	if _, err := failing.CompressIncremental(root, opts); err == nil {
+		t.Fatalf("expected an error without qzip")
+	}
+	if _, err := os.Stat(c + ".gz"); err != nil {
+		t.Fatalf("previous output was removed: %s", err)
+	}
+	if report, err = client.CompressIncremental(root, opts); err != nil || len(report.Results) != 1 {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+}
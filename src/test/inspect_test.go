@@ -0,0 +1,88 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 读取 gzip 成员的头部与尾部、gzipext 的块表以及 LZ4 帧描述符，不输出解压数据
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = "data.log"
+	zw.ModTime = time.Unix(1700000000, 0)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	buf.Write(gzipBytes(t, []byte("world!")))
+	plain := filepath.Join(dir, "plain.gz")
+	writeFile(t, plain, buf.String())
+	inspection, err := pkg.Inspect(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Format != pkg.FormatGzip || len(inspection.Members) != 2 || inspection.UncompressedSize != 11 || inspection.Size != int64(buf.Len()) {
+		t.Fatalf("unexpected inspection: %+v", inspection)
+	}
+	first := inspection.Members[0]
+	if first.Name != "data.log" || !first.ModTime.Equal(zw.ModTime) || first.CRC32 != crc32.ChecksumIEEE([]byte("hello")) || first.ISize != 5 || first.QZ {
+		t.Fatalf("unexpected member: %+v", first)
+	}
+
+	data := []byte(strings.Repeat("gzipext ", 1000))
+	ext := filepath.Join(dir, "ext.gz")
+	writeFile(t, ext, string(gzipExtBytes(t, data, 1000)))
+	inspection, err = pkg.Inspect(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Format != pkg.FormatGzipExt || len(inspection.Members) != 8 || inspection.UncompressedSize != int64(len(data)) {
+		t.Fatalf("unexpected inspection: %+v", inspection)
+	}
+	if m := inspection.Members[7]; !m.QZ || m.ChunkSize != 1000 || m.BlockSize == 0 {
+		t.Fatalf("unexpected block: %+v", m)
+	}
+
+	frame := lz4sFrame(0x40|0x10|0x08, [][]byte{lz4sBlock, nil}, []byte("stored"), append(append([]byte(nil), lz4sPlain...), "stored"...))
+	lz4 := filepath.Join(dir, "data.lz4")
+	writeFile(t, lz4, string(frame))
+	inspection, err = pkg.Inspect(lz4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Format != pkg.FormatLz4 || len(inspection.Frames) != 1 || len(inspection.Blocks) != 2 || inspection.UncompressedSize != int64(len(lz4sPlain)+6) {
+		t.Fatalf("unexpected inspection: %+v", inspection)
+	}
+	if f := inspection.Frames[0]; !f.BlockChecksum || f.Blocks != 2 || f.BlockMaxSize != 64<<10 {
+		t.Fatalf("unexpected frame: %+v", f)
+	}
+	if b := inspection.Blocks[1]; !b.Stored || b.CompressedSize != 6 {
+		t.Fatalf("unexpected block: %+v", b)
+	}
+
+	// 截断的文件返回已经读取的成员与错误
+	truncated := filepath.Join(dir, "truncated.gz")
+	writeFile(t, truncated, buf.String()[:buf.Len()-4])
+	if inspection, err := pkg.Inspect(truncated); err == nil || inspection == nil || len(inspection.Members) != 1 {
+		t.Fatalf("expected one member and an error, got %+v %v", inspection, err)
+	}
+
+	text := filepath.Join(dir, "data.txt")
+	writeFile(t, text, "not compressed")
+	if _, err := pkg.Inspect(text); !errors.Is(err, pkg.ErrNotCompressed) {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+	if _, err := pkg.Inspect(filepath.Join(dir, "missing.gz")); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg/lz4s"
+)
+
+// 一个 LZ4s 数据块及其解码结果：
+// "abcd" 之后匹配 offset 4 长度 6，"pq" 之后没有匹配，最后的序列只有字面量 "xyz"
+var (
+	lz4sBlock = []byte{
+		4<<4 | 4, 'a', 'b', 'c', 'd', 4, 0,
+		2 << 4, 'p', 'q', 0, 0,
+		3 << 4, 'x', 'y', 'z',
+	}
+	lz4sPlain = []byte("abcdabcdabpqxyz")
+)
+
+// 生成一个 LZ4 帧：flg 为帧描述符的 FLG 字节，blocks 中以 nil 表示追加一个未压缩的块 stored
+func lz4sFrame(flg byte, blocks [][]byte, stored []byte, content []byte) []byte {
+	frame := binary.LittleEndian.AppendUint32(nil, 0x184d2204)
+	descriptor := []byte{flg, 4 << 4}
+	if flg&0x08 != 0 {
+		descriptor = binary.LittleEndian.AppendUint64(descriptor, uint64(len(content)))
+	}
+	frame = append(frame, descriptor...)
+	frame = append(frame, byte(lz4s.Checksum(descriptor)>>8))
+	for _, block := range blocks {
+		size := uint32(len(block))
+		if block == nil {
+			block, size = stored, uint32(len(stored))|0x80000000
+		}
+		frame = binary.LittleEndian.AppendUint32(frame, size)
+		frame = append(frame, block...)
+		if flg&0x10 != 0 {
+			frame = binary.LittleEndian.AppendUint32(frame, lz4s.Checksum(block))
+		}
+	}
+	frame = binary.LittleEndian.AppendUint32(frame, 0)
+	if flg&0x04 != 0 {
+		frame = binary.LittleEndian.AppendUint32(frame, lz4s.Checksum(content))
+	}
+	return frame
+}
+
+// 解码带有各种校验和的帧、未压缩的块、依赖前一个块的匹配与 skippable 帧
+func TestLz4sDecompress(t *testing.T) {
+	if got := lz4s.Checksum([]byte("abc")); got != 0x32d153ff {
+		t.Fatalf("unexpected XXH32: %08x", got)
+	}
+	// 第三个块引用之前块的输出：offset 21 长度 5 得到 "abcda"
+	dependent := []byte{0<<4 | 3, 21, 0, 1 << 4, '!'}
+	want := append(append(append([]byte(nil), lz4sPlain...), "stored"...), "abcda!"...)
+	first := lz4sFrame(0x40|0x10|0x08|0x04, [][]byte{lz4sBlock, nil, dependent}, []byte("stored"), want)
+	skippable := binary.LittleEndian.AppendUint32(nil, 0x184d2a53)
+	skippable = binary.LittleEndian.AppendUint32(skippable, 3)
+	skippable = append(skippable, 1, 2, 3)
+	second := lz4sFrame(0x40|0x20, [][]byte{lz4sBlock}, nil, nil)
+	stream := append(append(append([]byte(nil), first...), skippable...), second...)
+	want = append(want, lz4sPlain...)
+
+	var out bytes.Buffer
+	n, err := lz4s.Decompress(&out, bytes.NewReader(stream))
+	if err != nil || n != int64(len(want)) || !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("unexpected output %q: %v", out.Bytes(), err)
+	}
+	r := lz4s.NewReader(bytes.NewReader(stream))
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("unexpected reader output: %v", err)
+	}
+
+	stats, err := lz4s.Inspect(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Frames) != 2 || len(stats.Blocks) != 4 || stats.UncompressedSize != int64(len(want)) || stats.CompressedSize != int64(len(stream)) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	frame := stats.Frames[0]
+	if !frame.BlockChecksum || !frame.ContentChecksum || !frame.HasContentSize || frame.BlockIndependent || frame.BlockMaxSize != 64<<10 || frame.Blocks != 3 {
+		t.Fatalf("unexpected frame: %+v", frame)
+	}
+	block := stats.Blocks[0]
+	if block.Sequences != 3 || block.Matches != 1 || block.MatchBytes != 6 || block.LiteralOnly != 2 || block.LiteralBytes != 9 || block.MaxOffset != 4 || block.Offset != 15 {
+		t.Fatalf("unexpected block: %+v", block)
+	}
+	if !stats.Blocks[1].Stored || stats.Blocks[3].Frame != 1 {
+		t.Fatalf("unexpected blocks: %+v", stats.Blocks)
+	}
+
+	scan, err := lz4s.Scan(bytes.NewReader(stream))
+	if err != nil || len(scan.Frames) != 2 || scan.Frames[1].Offset != int64(len(first)+len(skippable)) || !scan.Frames[1].BlockIndependent {
+		t.Fatalf("unexpected frames: %+v %v", scan, err)
+	}
+	if len(scan.Blocks) != 4 || scan.Blocks[0].CompressedSize != len(lz4sBlock) || scan.Blocks[1].UncompressedSize != len("stored") || scan.Blocks[0].Sequences != 0 {
+		t.Fatalf("unexpected scanned blocks: %+v", scan.Blocks)
+	}
+}
+
+// 损坏的数据返回 ErrCorrupt，并指出出错的位置
+func TestLz4sCorrupt(t *testing.T) {
+	valid := lz4sFrame(0x40|0x04, [][]byte{lz4sBlock}, nil, lz4sPlain)
+	cases := map[string]func(b []byte) []byte{
+		"magic":            func(b []byte) []byte { b[0] ^= 0xff; return b },
+		"header checksum":  func(b []byte) []byte { b[6] ^= 0xff; return b },
+		"match offset":     func(b []byte) []byte { b[11+5] = 9; return b },
+		"content checksum": func(b []byte) []byte { b[len(b)-1] ^= 0xff; return b },
+		"truncated":        func(b []byte) []byte { return b[:len(b)-6] },
+		"empty":            func(b []byte) []byte { return nil },
+	}
+	for name, corrupt := range cases {
+		stream := corrupt(append([]byte(nil), valid...))
+		if _, err := lz4s.Decompress(io.Discard, bytes.NewReader(stream)); !errors.Is(err, lz4s.ErrCorrupt) {
+			t.Fatalf("%s: expected ErrCorrupt, got %v", name, err)
+		}
+	}
+	if _, err := lz4s.DecodeBlock(nil, []byte{0<<4 | 1, 1, 0}); !errors.Is(err, lz4s.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt for a match before any output, got %v", err)
+	}
+}
+
+// 与输出重叠的匹配按周期复制；超过块大小上限的块在展开之前返回 ErrCorrupt
+func TestLz4sOverlapAndLimit(t *testing.T) {
+	cases := map[string]struct {
+		block []byte
+		want  string
+	}{
+		"offset 1": {[]byte{1<<4 | 15, 'a', 1, 0, 83}, strings.Repeat("a", 101)},
+		"offset 3": {[]byte{3<<4 | 8, 'a', 'b', 'c', 3, 0}, "abcabcabcabca"},
+	}
+	for name, c := range cases {
+		got, err := lz4s.DecodeBlock(nil, c.block)
+		if err != nil || string(got) != c.want {
+			t.Fatalf("%s: got %q, %v", name, got, err)
+		}
+	}
+
+	// 8MB 的长度扩展字节会展开为约 2GB
+	bomb := append([]byte{1<<4 | 15, 'a', 1, 0}, bytes.Repeat([]byte{255}, 8<<20)...)
+	bomb = append(bomb, 0)
+	if _, err := lz4s.DecodeBlock(nil, bomb); !errors.Is(err, lz4s.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+	// 帧的块大小上限为 64KB
+	block := append([]byte{1<<4 | 15, 'a', 1, 0}, bytes.Repeat([]byte{255}, 300)...)
+	block = append(block, 0)
+	frame := lz4sFrame(0x40, [][]byte{block}, nil, nil)
+	if _, err := lz4s.Decompress(io.Discard, bytes.NewReader(frame)); !errors.Is(err, lz4s.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+// Software 模式下在 Go 中解压 .lz4s 文件
+func TestSoftwareDecompressLz4s(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.log.lz4s")
+	if err := os.WriteFile(file, lz4sFrame(0x40, [][]byte{lz4sBlock}, nil, nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Exec.QzipPath = filepath.Join(dir, "no-qzip")
+	client.Software = true
+	if _, err := client.DecompressFile(file); err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "data.log"))
+	if err != nil || !bytes.Equal(got, lz4sPlain) {
+		t.Fatalf("unexpected output %q: %v", got, err)
+	}
+}
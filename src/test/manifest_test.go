@@ -0,0 +1,194 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// tar 归档的清单：以条目名称记录源文件，可以校验归档、解压后的目录，并且兼容 sha256sum -c
+func TestManifestTar(t *testing.T) {
+	for _, format := range []pkg.ManifestFormat{pkg.ManifestJSON, pkg.ManifestSHA256Sum} {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "data", "a.txt"), "a")
+		writeFile(t, filepath.Join(dir, "data", "sub", "b.txt"), "b")
+		archive := filepath.Join(dir, "data.tgz")
+
+		client := pkg.NewClient()
+		client.Exec.QzipPath = fakeQzip(t)
+		client.Manifest = &pkg.ManifestOptions{Format: format}
+		if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+			t.Fatalf("format=%d: compress failed: %s", format, err)
+		}
+		manifestPath := archive + ".manifest.json"
+		if format == pkg.ManifestSHA256Sum {
+			manifestPath = archive + ".sha256"
+		}
+		manifest, err := pkg.ReadManifest(manifestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, file := range manifest.Files {
+			paths = append(paths, file.Path)
+		}
+		if strings.Join(paths, ",") != "data/a.txt,data/sub/b.txt,data.tgz" {
+			t.Fatalf("format=%d: unexpected manifest: %+v", format, manifest)
+		}
+		if format == pkg.ManifestJSON && (manifest.Files[0].Role != pkg.RoleSource || manifest.Files[2].Role != pkg.RoleCompressed || manifest.Files[0].Size != 1) {
+			t.Fatalf("unexpected manifest: %+v", manifest)
+		}
+
+		report, err := client.VerifyManifest(manifestPath, archive)
+		if err != nil || len(report.Succeeded) != 3 {
+			t.Fatalf("format=%d: verify archive failed: %v %+v", format, err, report)
+		}
+		// 清单所在目录中包含源目录与归档
+		if _, err := client.VerifyManifest(manifestPath, ""); err != nil {
+			t.Fatalf("format=%d: verify directory failed: %v", format, err)
+		}
+		if format == pkg.ManifestSHA256Sum {
+			if _, err := exec.LookPath("sha256sum"); err == nil {
+				cmd := exec.Command("sha256sum", "-c", filepath.Base(manifestPath))
+				cmd.Dir = dir
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("sha256sum -c failed: %s %s", err, output)
+				}
+			}
+		}
+
+		writeFile(t, filepath.Join(dir, "data", "a.txt"), "modified")
+		report, err = client.VerifyManifest(manifestPath, "")
+		if !errors.Is(err, pkg.ErrManifestMismatch) || len(report.Failed) != 1 || report.Failed["data/a.txt"] == nil {
+			t.Fatalf("format=%d: expected a mismatch, got %v %+v", format, err, report)
+		}
+	}
+}
+
+// tar 归档的清单从归档中读取条目：硬链接只在归档中存储一次，清单中仍然记录两个条目
+func TestManifestFromArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data", "a.txt"), "a")
+	if err := os.Link(filepath.Join(dir, "data", "a.txt"), filepath.Join(dir, "data", "b.txt")); err != nil {
+		t.Skip(err)
+	}
+	archive := filepath.Join(dir, "data.tgz")
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Manifest = &pkg.ManifestOptions{}
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	// 压缩之后修改源文件不影响归档与清单的一致性
+	writeFile(t, filepath.Join(dir, "data", "new.txt"), "new")
+	manifest, err := pkg.ReadManifest(archive + ".manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := make(map[string]string)
+	for _, file := range manifest.Files {
+		sums[file.Path] = file.SHA256
+	}
+	if len(sums) != 3 || sums["data/a.txt"] == "" || sums["data/a.txt"] != sums["data/b.txt"] {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	if _, err := client.VerifyManifest(archive+".manifest.json", archive); err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+}
+
+// 逐个文件压缩的清单记录源文件与压缩文件，路径相对于清单所在目录
+func TestManifestTree(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "compressed")
+	writeFile(t, filepath.Join(src, "a.log"), "a")
+	writeFile(t, filepath.Join(src, "sub", "b.log"), "b")
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Manifest = &pkg.ManifestOptions{}
+	if _, err := client.CompressTree(src, dst, pkg.WalkOptions{}); err == nil || !strings.Contains(err.Error(), "manifest path") {
+		t.Fatalf("expected an error for an empty manifest path, got %v", err)
+	}
+	manifestPath := filepath.Join(dst, "MANIFEST.json")
+	client.Manifest.Path = manifestPath
+	if _, err := client.CompressTree(src, dst, pkg.WalkOptions{}); err != nil {
+		t.Fatalf("compress tree failed: %s", err)
+	}
+	manifest, err := pkg.ReadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 4 || manifest.Files[2].Path != "a.log.gz" || manifest.Files[3].Path != "sub/b.log.gz" || !strings.HasSuffix(manifest.Files[0].Path, "/a.log") {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	if _, err := client.VerifyManifest(manifestPath, ""); err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+
+	os.Remove(filepath.Join(dst, "sub", "b.log.gz"))
+	report, err := client.VerifyManifest(manifestPath, "")
+	if !errors.Is(err, os.ErrNotExist) || len(report.Succeeded) != 3 {
+		t.Fatalf("expected a missing file, got %v %+v", err, report)
+	}
+}
+
+// 多次增量压缩写入同一个清单：未变化的文件保留原有记录，变化的文件更新记录，删除的文件不再记录
+func TestManifestIncremental(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.log")
+	b := filepath.Join(root, "b.log")
+	writeFile(t, a, "a")
+	writeFile(t, b, "b")
+	manifestPath := filepath.Join(root, "MANIFEST.json")
+	opts := pkg.IncrementalOptions{StateFile: filepath.Join(t.TempDir(), "state.json"), RemoveStale: true}
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Manifest = &pkg.ManifestOptions{Path: manifestPath}
+	if _, err := client.CompressIncremental(root, opts); err != nil {
+		t.Fatalf("first run failed: %s", err)
+	}
+
+	c := filepath.Join(root, "c.log")
+	writeFile(t, c, "c")
+	writeFile(t, a, "a changed")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	report, err := client.CompressIncremental(root, opts)
+	if err != nil {
+		t.Fatalf("second run failed: %s", err)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	manifest, err := pkg.ReadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	if strings.Join(paths, ",") != "a.log,c.log,a.log.gz,c.log.gz" {
+		t.Fatalf("unexpected manifest: %q", paths)
+	}
+	if _, err := client.VerifyManifest(manifestPath, ""); err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+
+	// 第三次运行没有变化的文件，清单保持不变
+	report, err = client.CompressIncremental(root, opts)
+	if err != nil || len(report.Results) != 0 || len(report.Unchanged) != 2 {
+		t.Fatalf("unexpected third run: %v %+v", err, report)
+	}
+	if manifest, err = pkg.ReadManifest(manifestPath); err != nil || len(manifest.Files) != 4 {
+		t.Fatalf("unexpected manifest: %v %+v", err, manifest)
+	}
+}
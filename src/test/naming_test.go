@@ -0,0 +1,94 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 命名模板的占位符替换与错误检查
+func TestRenderNameTemplate(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	cases := map[string]string{
+		"{dir}/{stem}.{date}{ext}":           "/data/test.20240506.lz4",
+		"{dir}/{name}{ext}":                  "/data/test.json.lz4",
+		"{dir}/out/{stem}-{time}{fext}{ext}": "/data/out/test-070809.json.lz4",
+	}
+	for template, want := range cases {
+		got, err := internal.RenderNameTemplate(template, "/data/test.json", ".lz4", now)
+		if err != nil || got != want {
+			t.Fatalf("%s: got %q %v, want %q", template, got, err, want)
+		}
+	}
+	for _, template := range []string{"{dir}/{unknown}{ext}", "{dir}/{stem"} {
+		if _, err := internal.RenderNameTemplate(template, "/data/test.json", ".gz", now); err == nil {
+			t.Fatalf("%s: expected an error", template)
+		}
+	}
+}
+
+// 压缩后缀与算法对应，解压时对称地去除后缀，未知后缀返回明确的错误
+func TestAlgorithmExtensions(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.json")
+	writeFile(t, file, "{}")
+	for algorithm, ext := range map[internal.ALGORITHM_TYPE]string{internal.GZIP: ".gz", internal.LZ4: ".lz4", internal.LZ4S: ".lz4s"} {
+		cmd := internal.GetDefaultQzipCommand()
+		cmd.Algorithm = algorithm
+		cmd.InputFile = []string{file}
+		targets, err := internal.QzipTargets(cmd)
+		if err != nil || targets[0].Output != file+ext {
+			t.Fatalf("%s: unexpected targets %+v %v", algorithm, targets, err)
+		}
+		writeFile(t, file+ext, "")
+		cmd.Compression = false
+		cmd.InputFile = []string{file + ext}
+		if targets, err = internal.QzipTargets(cmd); err != nil || targets[0].Output != file {
+			t.Fatalf("%s: unexpected targets %+v %v", algorithm, targets, err)
+		}
+	}
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	if _, err := client.DecompressFile(file); !errors.Is(err, pkg.ErrUnknownSuffix) {
+		t.Fatalf("expected ErrUnknownSuffix, got %v", err)
+	}
+}
+
+// 按模板命名压缩输出，解压后得到原始文件名
+func TestNameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.json")
+	writeFile(t, file, `{"a":1}`)
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.NameTemplate = "{dir}/{stem}.{date}{ext}"
+	for _, atomic := range []bool{true, false} {
+		client.Atomic = atomic
+		report, err := client.CompressFiles(file)
+		if err != nil {
+			t.Fatalf("atomic=%v: compress failed: %s", atomic, err)
+		}
+		want := filepath.Join(dir, "test."+time.Now().Format("20060102")+".gz")
+		if report.Results[0].Output != want {
+			t.Fatalf("atomic=%v: unexpected output %s", atomic, report.Results[0].Output)
+		}
+		if _, err := os.Stat(want); err != nil {
+			t.Fatalf("atomic=%v: %s", atomic, err)
+		}
+		restored := filepath.Join(dir, "restored.json")
+		if _, err := client.DecompressWithOutputFile(want, restored); err != nil {
+			t.Fatalf("atomic=%v: decompress failed: %s", atomic, err)
+		}
+		if data, _ := os.ReadFile(restored); string(data) != `{"a":1}` {
+			t.Fatalf("atomic=%v: unexpected restored content %q", atomic, data)
+		}
+		os.Remove(want)
+		os.Remove(restored)
+	}
+}
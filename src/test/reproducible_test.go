@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 相同内容的目录树，创建顺序、修改时间与权限不同时生成相同的 tar 数据流
+//
+// gzip 头（修改时间、OS 字节与 QZ 扩展字段）由压缩器写入，只比较解压后的 tar 数据
+func TestReproducibleTar(t *testing.T) {
+	dir := t.TempDir()
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Reproducible = true
+
+	first := filepath.Join(dir, "1", "data")
+	writeFile(t, filepath.Join(first, "a.txt"), "a")
+	writeFile(t, filepath.Join(first, "sub", "b.txt"), "b")
+	writeFile(t, filepath.Join(first, "z.txt"), "z")
+	second := filepath.Join(dir, "2", "data")
+	writeFile(t, filepath.Join(second, "z.txt"), "z")
+	writeFile(t, filepath.Join(second, "sub", "b.txt"), "b")
+	writeFile(t, filepath.Join(second, "a.txt"), "a")
+	os.Chmod(filepath.Join(second, "a.txt"), 0o664)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(second, "z.txt"), old, old)
+
+	var archives [][]byte
+	for _, src := range []string{first, second} {
+		archive := filepath.Join(filepath.Dir(src), "data.tgz")
+		if _, err := client.CompressDictoryByTar(src, archive); err != nil {
+			t.Fatalf("compress failed: %s", err)
+		}
+		f, err := os.Open(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, data)
+	}
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Fatalf("tar streams differ")
+	}
+
+	out, err := exec.Command("tar", "-tvzf", filepath.Join(dir, "2", "data.tgz")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("tar failed: %s: %s", err, out)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if fields[1] != "0/0" || fields[3] != "1970-01-01" {
+			t.Fatalf("entry not normalised: %s", line)
+		}
+		if strings.HasSuffix(fields[5], ".txt") && fields[0] != "-rw-r--r--" {
+			t.Fatalf("mode not normalised: %s", line)
+		}
+		names = append(names, fields[5])
+	}
+	if want := []string{"data/", "data/a.txt", "data/sub/", "data/sub/b.txt", "data/z.txt"}; !slices.Equal(names, want) {
+		t.Fatalf("unexpected entries %v", names)
+	}
+
+	// 解压不使用可复现选项
+	client.DryRun = true
+	report, err := client.DecompressDictoryByTar(filepath.Join(dir, "2", "data.tgz"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(report.Plans[0].Argv, "--sort=name") {
+		t.Fatalf("unexpected plan %v", report.Plans[0].Argv)
+	}
+}
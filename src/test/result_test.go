@@ -0,0 +1,44 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 每个输入文件都有对应的结果，包含大小、压缩比以及 qzip 统计信息
+func TestCompressFilesResults(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "1.txt")
+	b := filepath.Join(dir, "2.txt")
+	writeFile(t, a, strings.Repeat("qzipgo ", 4096))
+	writeFile(t, b, strings.Repeat("abcdefgh", 1024))
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	report, err := client.CompressFiles(a, b)
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("expected two results, got %d", len(report.Results))
+	}
+	for i, input := range []string{a, b} {
+		result := report.Results[i]
+		if result.Input != input || result.Output != input+".gz" {
+			t.Fatalf("unexpected paths: %+v", result)
+		}
+		if result.BytesIn == 0 || result.BytesOut == 0 || result.Ratio <= 1 {
+			t.Fatalf("unexpected sizes: %+v", result)
+		}
+		if result.QzipTime != 1500*time.Microsecond || result.Throughput != 8 {
+			t.Fatalf("unexpected qzip stats: %+v", result)
+		}
+		if result.Algorithm != "gzipext" {
+			t.Fatalf("unexpected algorithm: %s", result.Algorithm)
+		}
+	}
+}
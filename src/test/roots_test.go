@@ -0,0 +1,170 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/internal"
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 列出归档中的条目名称
+func tarNames(t *testing.T, archive string) []string {
+	t.Helper()
+	out, err := exec.Command("tar", "-tzf", archive).CombinedOutput()
+	if err != nil {
+		t.Fatalf("tar failed: %s: %s", err, out)
+	}
+	return strings.Fields(string(out))
+}
+
+// 来自不同父目录的输入按给定路径写入同一个归档
+func TestCompressRootsByTar(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "web", "data", "index.html"), "index")
+	writeFile(t, filepath.Join(dir, "api", "data", "routes.txt"), "routes")
+	writeFile(t, filepath.Join(dir, "etc", "app.conf"), "conf")
+	os.Symlink("index.html", filepath.Join(dir, "web", "data", "home.html"))
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Manifest = &pkg.ManifestOptions{}
+	roots := []pkg.TarRoot{
+		{Source: filepath.Join(dir, "web", "data"), Prefix: "srv/web"},
+		{Source: filepath.Join(dir, "api", "data"), Prefix: "srv/api/"},
+		{Source: filepath.Join(dir, "etc", "app.conf")},
+	}
+	report, err := client.CompressRootsByTar(filepath.Join(dir, "bundle"), roots...)
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	archive := filepath.Join(dir, "bundle.tgz")
+	if len(report.Results) != 1 || report.Results[0].Output != archive || report.Results[0].BytesIn != int64(len("indexroutesconf")) {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+	want := []string{"srv/", "srv/web/", "srv/web/home.html", "srv/web/index.html", "srv/api/", "srv/api/routes.txt", "app.conf"}
+	if names := tarNames(t, archive); !slices.Equal(names, want) {
+		t.Fatalf("unexpected entries %v", names)
+	}
+	if _, err := client.VerifyManifest(archive+".manifest.json", archive); err != nil {
+		t.Fatalf("verify manifest failed: %s", err)
+	}
+	restored := filepath.Join(dir, "restored")
+	os.MkdirAll(restored, 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, restored); err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if link, err := os.Readlink(filepath.Join(restored, "srv", "web", "home.html")); err != nil || link != "index.html" {
+		t.Fatalf("unexpected link %q: %v", link, err)
+	}
+
+	// 可复现：输入顺序不同时生成相同的归档
+	client.Manifest = nil
+	client.Reproducible = true
+	client.Collision = pkg.CollisionOverwrite
+	var archives []string
+	for _, order := range [][]pkg.TarRoot{roots, {roots[2], roots[1], roots[0]}} {
+		if _, err := client.CompressRootsByTar(archive, order...); err != nil {
+			t.Fatalf("compress failed: %s", err)
+		}
+		data, _ := os.ReadFile(archive)
+		archives = append(archives, string(data))
+	}
+	if archives[0] != archives[1] {
+		t.Fatalf("reproducible archives differ")
+	}
+	// 隐含的上级目录与其他条目一样统一元数据
+	entries, err := client.ListArchive(archive)
+	if err != nil || entries[0].Name != "app.conf" || entries[1].Name != "srv/" {
+		t.Fatalf("unexpected entries %+v: %v", entries, err)
+	}
+	if parent := entries[1]; !parent.Mode.IsDir() || parent.Mode.Perm() != 0o755 || parent.ModTime.Unix() != 0 || parent.Uid != 0 {
+		t.Fatalf("unexpected parent entry %+v", parent)
+	}
+
+	// DryRun 的计划列出输入
+	client.DryRun = true
+	report, err = client.CompressRootsByTar(archive, roots...)
+	if err != nil || len(report.Plans) != 1 || len(report.Plans[0].Inputs) != 3 || report.Plans[0].Inputs[2] != roots[2].Source {
+		t.Fatalf("unexpected plans %+v: %v", report.Plans, err)
+	}
+}
+
+// 冲突的条目在写入任何输出之前返回 ErrEntryCollision
+func TestCompressRootsCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "data", "x.txt"), "a")
+	writeFile(t, filepath.Join(dir, "a", "data", "only-a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "b", "data", "x.txt"), "b")
+	writeFile(t, filepath.Join(dir, "c", "x.txt"), "c")
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	cases := [][]pkg.TarRoot{
+		// 同名的目录合并，其中的文件冲突
+		{{Source: filepath.Join(dir, "a", "data")}, {Source: filepath.Join(dir, "b", "data")}},
+		// 文件与目录中的文件冲突
+		{{Source: filepath.Join(dir, "a", "data"), Prefix: "d"}, {Source: filepath.Join(dir, "c", "x.txt"), Prefix: "d/x.txt"}},
+		// 文件与目录冲突
+		{{Source: filepath.Join(dir, "a", "data"), Prefix: "d"}, {Source: filepath.Join(dir, "c", "x.txt"), Prefix: "d"}},
+		// 文件与 Prefix 隐含的上级目录冲突
+		{{Source: filepath.Join(dir, "c", "x.txt"), Prefix: "d"}, {Source: filepath.Join(dir, "a", "data"), Prefix: "d/data"}},
+	}
+	archive := filepath.Join(dir, "out.tgz")
+	for i, roots := range cases {
+		if _, err := client.CompressRootsByTar(archive, roots...); !errors.Is(err, pkg.ErrEntryCollision) {
+			t.Fatalf("case %d: expected ErrEntryCollision, got %v", i, err)
+		}
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("archive written despite collisions")
+	}
+	for _, prefix := range []string{"/abs", "..", "../up", "."} {
+		if _, err := client.CompressRootsByTar(archive, pkg.TarRoot{Source: filepath.Join(dir, "c"), Prefix: prefix}); err == nil {
+			t.Fatalf("expected an error for prefix %q", prefix)
+		}
+	}
+	// 同名的目录合并
+	if _, err := client.CompressRootsByTar(archive, pkg.TarRoot{Source: filepath.Join(dir, "a", "data"), Prefix: "d"}, pkg.TarRoot{Source: filepath.Join(dir, "c"), Prefix: "d/sub"}); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+}
+
+// ExecuteTarCommand 的输入位于不同的父目录时，每个输入在其父目录中归档
+func TestTarInputsFromDifferentParents(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "one", "1.txt"), "1")
+	writeFile(t, filepath.Join(dir, "b", "two", "2.txt"), "2")
+	writeFile(t, filepath.Join(dir, "b", "one", "1.txt"), "1")
+
+	cmd := internal.GetDefaultTarCommand()
+	cmd.Exec.QzipPath = fakeQzip(t)
+	cmd.Compression = true
+	cmd.ArchiveFile = filepath.Join(dir, "out.tgz")
+	cmd.InputFile = []string{filepath.Join(dir, "a", "one"), filepath.Join(dir, "b", "two")}
+	var logs bytes.Buffer
+	cmd.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	if err := internal.ExecuteTarCommand(cmd); err != nil {
+		t.Fatalf("tar failed: %s", err)
+	}
+	// 日志记录原始的输入，而不是 -C 参数
+	var executing struct{ File []string }
+	if err := json.Unmarshal([]byte(strings.SplitN(logs.String(), "\n", 2)[0]), &executing); err != nil || !slices.Equal(executing.File, cmd.InputFile) {
+		t.Fatalf("unexpected log record: %s", logs.String())
+	}
+	if names := tarNames(t, cmd.ArchiveFile); !slices.Equal(names, []string{"one/", "one/1.txt", "two/", "two/2.txt"}) {
+		t.Fatalf("unexpected entries %v", names)
+	}
+	cmd.InputFile = []string{filepath.Join(dir, "a", "one"), filepath.Join(dir, "b", "one")}
+	if err := internal.ExecuteTarCommand(cmd); !errors.Is(err, internal.ErrEntryCollision) {
+		t.Fatalf("expected ErrEntryCollision, got %v", err)
+	}
+}
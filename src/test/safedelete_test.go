@@ -0,0 +1,163 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 只复制输入的 qzip：解压的输出与源文件内容不一致
+const copyQzipScript = `#!/bin/sh
+out=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-o) shift; out="$1" ;;
+	-A|-O|-L|-r|-P) shift ;;
+	-*) ;;
+	*) break ;;
+	esac
+	shift
+done
+cp "$1" "${out:-${1%.gz}}"
+`
+
+// 输出校验通过后才删除源文件，可以移动到隔离目录
+func TestSafeDelete(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		dir := t.TempDir()
+		quarantine := filepath.Join(t.TempDir(), "quarantine")
+		file := filepath.Join(dir, "a.log")
+		writeFile(t, file, "verified content")
+		client := pkg.NewClient()
+		client.Exec.QzipPath = fakeQzip(t)
+		client.Atomic = atomic
+		if _, err := client.CompressFile(file); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(file); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+		if err := os.Chtimes(file+".gz", modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		client.SafeDelete = &pkg.SafeDeleteOptions{QuarantineDir: quarantine}
+		report, err := client.DecompressFiles(file + ".gz")
+		if err != nil {
+			t.Fatalf("atomic=%v: decompress failed: %s", atomic, err)
+		}
+		result := report.Results[0]
+		if !result.Verified || filepath.Base(result.Quarantined) != "a.log.gz" || filepath.Dir(filepath.Dir(result.Quarantined)) != quarantine {
+			t.Fatalf("atomic=%v: unexpected result: %+v", atomic, result)
+		}
+		// 隔离文件保留原来的修改时间
+		if info, err := os.Stat(result.Quarantined); err != nil || !info.ModTime().Equal(modTime) {
+			t.Fatalf("atomic=%v: unexpected quarantined file %v: %v", atomic, info, err)
+		}
+		if _, err := os.Stat(file + ".gz"); !os.IsNotExist(err) {
+			t.Fatalf("atomic=%v: source was not removed", atomic)
+		}
+		if data, _ := os.ReadFile(file); string(data) != "verified content" {
+			t.Fatalf("atomic=%v: unexpected output %q", atomic, data)
+		}
+
+		// 保留时间按移入的时间计算，与文件的修改时间无关
+		if removed, err := pkg.PurgeQuarantine(quarantine, time.Hour); err != nil || len(removed) != 0 {
+			t.Fatalf("atomic=%v: unexpected purge: %q %v", atomic, removed, err)
+		}
+		// 隔离目录中的其他文件不会被删除
+		other := filepath.Join(quarantine, "other.log")
+		writeFile(t, other, "not quarantined")
+		past := time.Now().Add(-48 * time.Hour)
+		os.Chtimes(other, past, past)
+		// 超过保留时间的隔离文件被删除
+		old := filepath.Join(quarantine, time.Now().Add(-2*time.Hour).UTC().Format("20060102T150405Z"))
+		if err := os.Rename(filepath.Dir(result.Quarantined), old); err != nil {
+			t.Fatal(err)
+		}
+		removed, err := pkg.PurgeQuarantine(quarantine, time.Hour)
+		if err != nil || !reflect.DeepEqual(removed, []string{filepath.Join(old, "a.log.gz")}) {
+			t.Fatalf("atomic=%v: unexpected purge: %q %v", atomic, removed, err)
+		}
+		if _, err := os.Stat(old); !os.IsNotExist(err) {
+			t.Fatalf("atomic=%v: quarantine directory was not removed", atomic)
+		}
+		if _, err := os.Stat(other); err != nil {
+			t.Fatalf("atomic=%v: unrelated file removed: %v", atomic, err)
+		}
+	}
+}
+
+// LZ4s 文件在 Go 中解压后与输出比较
+func TestSafeDeleteLz4s(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.log.lz4s")
+	if err := os.WriteFile(file, lz4sFrame(0x40, [][]byte{lz4sBlock}, nil, nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Exec.QzipPath = filepath.Join(dir, "no-qzip")
+	client.Software = true
+	client.SafeDelete = &pkg.SafeDeleteOptions{}
+	report, err := client.DecompressFiles(file)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if !report.Results[0].Verified || len(report.Unverified) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("verified source was not removed")
+	}
+}
+
+// 输出与源文件不一致时保留源文件；无法校验的格式保留源文件并报告
+func TestSafeDeleteKeepsSource(t *testing.T) {
+	dir := t.TempDir()
+	qzip := filepath.Join(t.TempDir(), "qzip")
+	if err := os.WriteFile(qzip, []byte(copyQzipScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	client := pkg.NewClient()
+	client.Atomic = true
+	client.Exec.QzipPath = fakeQzip(t)
+	good := filepath.Join(dir, "a.log")
+	writeFile(t, good, "content")
+	if _, err := client.CompressFile(good); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(good); err != nil {
+		t.Fatal(err)
+	}
+	// LZ4 frame 无法用 gzip 校验
+	other := filepath.Join(dir, "b.log.lz4")
+	writeFile(t, other, "\x04\x22\x4d\x18lz4 data")
+
+	client.Exec.QzipPath = qzip
+	client.SafeDelete = &pkg.SafeDeleteOptions{}
+	if _, err := client.DecompressFiles(good + ".gz"); err == nil {
+		t.Fatalf("expected a verification error")
+	}
+	if _, err := os.Stat(good + ".gz"); err != nil {
+		t.Fatalf("source removed after a failed verification: %s", err)
+	}
+	if _, err := os.Stat(good); !os.IsNotExist(err) {
+		t.Fatalf("unverified output written")
+	}
+
+	report, err := client.DecompressFiles(other)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if !reflect.DeepEqual(report.Unverified, []string{other}) {
+		t.Fatalf("unexpected unverified files: %q", report.Unverified)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("unverified source removed: %s", err)
+	}
+}
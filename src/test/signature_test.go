@@ -0,0 +1,154 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 生成 ed25519 密钥对并写入 PEM 文件，返回私钥与公钥文件路径
+func writeKeyPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+	writeFile(t, privatePath, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	writeFile(t, publicPath, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})))
+	return privatePath, publicPath
+}
+
+// 压缩时为归档与清单签名，解压前拒绝被修改的归档
+func TestSignature(t *testing.T) {
+	dir := t.TempDir()
+	privatePath, publicPath := writeKeyPair(t, t.TempDir())
+	private, err := pkg.LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := pkg.LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pkg.LoadPublicKey(privatePath); err == nil {
+		t.Fatalf("expected an error for a private key file")
+	}
+
+	writeFile(t, filepath.Join(dir, "data", "a.txt"), "a")
+	archive := filepath.Join(dir, "data.tgz")
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Manifest = &pkg.ManifestOptions{}
+	client.Signature = &pkg.SignatureOptions{PrivateKey: private, PublicKey: public}
+	report, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive)
+	if err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	if report.Results[0].Signature != archive+pkg.SignatureExtension {
+		t.Fatalf("unexpected result: %+v", report.Results[0])
+	}
+	manifestPath := archive + ".manifest.json"
+	if err := pkg.VerifyFile(manifestPath, public); err != nil {
+		t.Fatalf("manifest signature: %s", err)
+	}
+	if _, err := client.VerifyManifest(manifestPath, archive); err != nil {
+		t.Fatalf("verify manifest failed: %s", err)
+	}
+	os.MkdirAll(filepath.Join(dir, "restored"), 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, filepath.Join(dir, "restored")); err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+
+	// 被修改的归档与清单在解压或校验前被拒绝
+	data, _ := os.ReadFile(archive)
+	writeFile(t, archive, string(append(data, 0)))
+	restored := filepath.Join(dir, "rejected")
+	os.MkdirAll(restored, 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, restored); !errors.Is(err, pkg.ErrSignature) {
+		t.Fatalf("expected ErrSignature, got %v", err)
+	}
+	if entries, _ := os.ReadDir(restored); len(entries) != 0 {
+		t.Fatalf("rejected archive was extracted")
+	}
+	manifest, _ := os.ReadFile(manifestPath)
+	writeFile(t, manifestPath, string(manifest)+" ")
+	if _, err := client.VerifyManifest(manifestPath, ""); !errors.Is(err, pkg.ErrSignature) {
+		t.Fatalf("expected ErrSignature, got %v", err)
+	}
+
+	os.Remove(archive + pkg.SignatureExtension)
+	if err := pkg.VerifyFile(archive, public); !os.IsNotExist(err) {
+		t.Fatalf("expected a missing signature, got %v", err)
+	}
+}
+
+// 签名验证后被替换的归档不会被解压：tar 从验证时打开的文件读取
+func TestSignatureArchiveSwapped(t *testing.T) {
+	dir := t.TempDir()
+	privatePath, publicPath := writeKeyPair(t, t.TempDir())
+	private, err := pkg.LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := pkg.LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "data", "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "evil", "data", "evil.txt"), "evil")
+	archive := filepath.Join(dir, "data.tgz")
+	evil := filepath.Join(dir, "evil.tgz")
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "evil", "data"), evil); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+	client.Signature = &pkg.SignatureOptions{PrivateKey: private, PublicKey: public}
+	if _, err := client.CompressDictoryByTar(filepath.Join(dir, "data"), archive); err != nil {
+		t.Fatalf("compress failed: %s", err)
+	}
+
+	// tar 启动时（签名已经验证）把归档替换为未签名的归档
+	tarPath, err := exec.LookPath("tar")
+	if err != nil {
+		t.Skip("tar not found")
+	}
+	wrapper := filepath.Join(t.TempDir(), "tar")
+	script := fmt.Sprintf("#!/bin/sh\nmv %q %q\nexec %q \"$@\"\n", evil, archive, tarPath)
+	if err := os.WriteFile(wrapper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	client.Exec.TarPath = wrapper
+	restored := filepath.Join(dir, "restored")
+	os.MkdirAll(restored, 0o755)
+	if _, err := client.DecompressDictoryByTar(archive, restored); err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if _, err := os.Stat(evil); !os.IsNotExist(err) {
+		t.Fatalf("archive was not swapped")
+	}
+	if _, err := os.Stat(filepath.Join(restored, "data", "a.txt")); err != nil {
+		t.Fatalf("verified archive was not extracted: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(restored, "data", "evil.txt")); !os.IsNotExist(err) {
+		t.Fatalf("swapped archive was extracted")
+	}
+}
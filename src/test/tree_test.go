@@ -0,0 +1,70 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 压缩到另一个目录并解压回第三个目录，保持相对目录结构，源目录不变
+func TestCompressAndDecompressTree(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "compressed")
+	restored := filepath.Join(t.TempDir(), "restored")
+	files := map[string]string{
+		"a.log":          "a",
+		"sub/b.log":      "b",
+		"sub/deep/c.log": "c",
+	}
+	for rel, content := range files {
+		writeFile(t, filepath.Join(src, rel), content)
+	}
+
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+	client.Parallelism = 2
+	report, err := client.CompressTree(src, dst, pkg.WalkOptions{})
+	if err != nil {
+		t.Fatalf("compress tree failed: %s", err)
+	}
+	if len(report.Succeeded) != len(files) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for rel := range files {
+		if _, err := os.Stat(filepath.Join(dst, rel+".gz")); err != nil {
+			t.Fatalf("missing compressed file: %s", err)
+		}
+		if _, err := os.Stat(filepath.Join(src, rel+".gz")); !os.IsNotExist(err) {
+			t.Fatalf("output written next to the source: %s", rel)
+		}
+	}
+
+	if _, err := client.DecompressTree(dst, restored, pkg.WalkOptions{}); err != nil {
+		t.Fatalf("decompress tree failed: %s", err)
+	}
+	for rel, content := range files {
+		data, err := os.ReadFile(filepath.Join(restored, rel))
+		if err != nil || string(data) != content {
+			t.Fatalf("unexpected restored file %s: %q %v", rel, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(dst, rel+".gz")); err != nil {
+			t.Fatalf("compressed source removed: %s", err)
+		}
+	}
+
+	if _, err := client.CompressTree(src, src, pkg.WalkOptions{}); err == nil {
+		t.Fatalf("expected an error when the output directory is the input directory")
+	}
+	// 输出目录位于输入目录之中（包括尚不存在的目录）时，下一次压缩会处理上一次的输出
+	for _, nested := range []string{filepath.Join(src, "a"), filepath.Join(src, "new", "out")} {
+		if _, err := client.CompressTree(src, nested, pkg.WalkOptions{}); err == nil || !strings.Contains(err.Error(), "inside the input directory") {
+			t.Fatalf("expected an error for the nested output directory %s, got %v", nested, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(src, "new")); !os.IsNotExist(err) {
+		t.Fatalf("nested output directory created")
+	}
+}
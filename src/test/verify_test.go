@@ -0,0 +1,71 @@
+package test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 校验目录中的压缩文件：报告损坏的 gzip 成员与 tar 条目的偏移，跳过未压缩的文件
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	first := gzipBytes(t, []byte("first member"))
+	good := append(append([]byte(nil), first...), gzipBytes(t, []byte("second member"))...)
+	writeFile(t, filepath.Join(dir, "good.gz"), string(good))
+	writeFile(t, filepath.Join(dir, "good.tgz"), string(gzipBytes(t, tarBytes(t, "a.txt", "content"))))
+	writeFile(t, filepath.Join(dir, "plain.txt"), "not compressed")
+
+	// 破坏第二个成员尾部的 CRC32
+	bad := append([]byte(nil), good...)
+	bad[len(bad)-5] ^= 0xff
+	writeFile(t, filepath.Join(dir, "sub", "bad.gz"), string(bad))
+
+	// 第二个条目的数据被截断
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	tw.WriteHeader(&tar.Header{Name: "first.txt", Mode: 0o644, Size: 600})
+	tw.Write([]byte(strings.Repeat("a", 600)))
+	tw.WriteHeader(&tar.Header{Name: "second.txt", Mode: 0o644, Size: 1000})
+	tw.Write([]byte(strings.Repeat("b", 1000)))
+	writeFile(t, filepath.Join(dir, "sub", "bad.tgz"), string(gzipBytes(t, archive.Bytes()[:1536+512+100])))
+
+	for _, software := range []bool{false, true} {
+		client := pkg.NewClient()
+		client.Exec.QzipPath = fakeQzip(t)
+		client.Software = software
+		client.Parallelism = 2
+		report, err := client.Verify(pkg.BatchOptions{ContinueOnError: true}, dir)
+		if err == nil {
+			t.Fatalf("software=%v: expected an error", software)
+		}
+		if len(report.Succeeded) != 2 || len(report.Failed) != 2 || len(report.Results) != 2 {
+			t.Fatalf("software=%v: unexpected report: %+v", software, report)
+		}
+		if report.Results[0].BytesOut != int64(len("first membersecond member")) {
+			t.Fatalf("software=%v: unexpected result: %+v", software, report.Results[0])
+		}
+
+		var integrity *pkg.IntegrityError
+		if !errors.As(report.Failed[filepath.Join(dir, "sub", "bad.gz")], &integrity) {
+			t.Fatalf("software=%v: expected an IntegrityError, got %v", software, report.Failed)
+		}
+		if integrity.Offset != int64(len(first)) || integrity.EntryOffset != -1 {
+			t.Fatalf("software=%v: unexpected error: %s", software, integrity)
+		}
+		if !errors.As(report.Failed[filepath.Join(dir, "sub", "bad.tgz")], &integrity) {
+			t.Fatalf("software=%v: expected an IntegrityError, got %v", software, report.Failed)
+		}
+		if integrity.Entry != "second.txt" || integrity.EntryOffset != 1536 {
+			t.Fatalf("software=%v: unexpected error: %s", software, integrity)
+		}
+	}
+
+	if _, err := pkg.NewClient().Verify(pkg.BatchOptions{}, filepath.Join(dir, "plain.txt")); !errors.Is(err, pkg.ErrNotCompressed) {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 按 glob、正则、大小、深度、隐藏文件与符号链接规则选择文件
+func TestSelectFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.log"), strings.Repeat("a", 100))
+	writeFile(t, filepath.Join(root, "b.log.gz"), "already compressed")
+	writeFile(t, filepath.Join(root, "small.log"), "s")
+	writeFile(t, filepath.Join(root, ".hidden.log"), strings.Repeat("h", 100))
+	writeFile(t, filepath.Join(root, "sub", "c.log"), strings.Repeat("c", 100))
+	writeFile(t, filepath.Join(root, "sub", "deep", "d.log"), strings.Repeat("d", 100))
+	writeFile(t, filepath.Join(root, "tmp", "e.log"), strings.Repeat("e", 100))
+	writeFile(t, filepath.Join(root, "notes.txt"), strings.Repeat("n", 100))
+	if err := os.Symlink(filepath.Join(root, "a.log"), filepath.Join(root, "link.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := pkg.WalkOptions{
+		Include:        []string{"*.log"},
+		Exclude:        []string{"tmp"},
+		ExcludeRegexp:  []string{`^sub/deep/`},
+		MinSize:        10,
+		SkipCompressed: true,
+	}
+	files, err := pkg.SelectFiles(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "a.log"), filepath.Join(root, "sub", "c.log")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected files: %q", files)
+	}
+
+	opts.MaxDepth = 1
+	opts.IncludeHidden = true
+	opts.Symlinks = pkg.SymlinkFollow
+	files, err = pkg.SelectFiles(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{filepath.Join(root, ".hidden.log"), filepath.Join(root, "a.log"), filepath.Join(root, "link.log")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected files: %q", files)
+	}
+
+	if _, err := pkg.SelectFiles(root, pkg.WalkOptions{IncludeRegexp: []string{"("}}); err == nil {
+		t.Fatalf("expected an error for an invalid regular expression")
+	}
+}
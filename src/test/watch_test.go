@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ordinary-xiyv/qzipgo/src/pkg"
+)
+
+// 监控目录：新写入的文件在稳定之后被压缩，已经压缩的文件与忽略的文件不会被处理
+func TestWatch(t *testing.T) {
+	for _, polling := range []bool{false, true} {
+		name := "inotify"
+		if polling {
+			name = "polling"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			client := pkg.NewClient()
+			client.Exec.QzipPath = fakeQzip(t)
+
+			var mu sync.Mutex
+			done := make(map[string]error)
+			opts := pkg.WatchOptions{
+				Debounce:     20 * time.Millisecond,
+				QuietPeriod:  100 * time.Millisecond,
+				Ignore:       []string{"*.tmp"},
+				Recursive:    true,
+				Polling:      polling,
+				PollInterval: 20 * time.Millisecond,
+				OnResult: func(file string, report *pkg.Report, err error) {
+					mu.Lock()
+					done[file] = err
+					mu.Unlock()
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			watchErr := make(chan error, 1)
+			go func() { watchErr <- client.Watch(ctx, opts, dir) }()
+			// 等待监控建立（轮询模式的第一次扫描只建立基线）
+			time.Sleep(100 * time.Millisecond)
+
+			writeFile(t, filepath.Join(dir, "a.log"), "a")
+			writeFile(t, filepath.Join(dir, "sub", "b.log"), "b")
+			writeFile(t, filepath.Join(dir, "c.tmp"), "c")
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				mu.Lock()
+				n := len(done)
+				mu.Unlock()
+				if n >= 2 || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			// 给可能错误处理的文件留出时间
+			time.Sleep(200 * time.Millisecond)
+			cancel()
+			if err := <-watchErr; err != context.Canceled {
+				t.Fatalf("unexpected watch error: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(done) != 2 {
+				t.Fatalf("unexpected compressed files: %v", done)
+			}
+			for _, rel := range []string{"a.log", filepath.Join("sub", "b.log")} {
+				if err, ok := done[filepath.Join(dir, rel)]; !ok || err != nil {
+					t.Fatalf("%s not compressed: %v", rel, err)
+				}
+				if _, err := os.Stat(filepath.Join(dir, rel+".gz")); err != nil {
+					t.Fatalf("missing compressed file: %s", err)
+				}
+			}
+		})
+	}
+}
+
+// 队列与等待列表已满时暂停读取事件，之后到达的文件仍然全部被压缩
+func TestWatchBackpressure(t *testing.T) {
+	dir := t.TempDir()
+	client := pkg.NewClient()
+	client.Exec.QzipPath = fakeQzip(t)
+
+	var mu sync.Mutex
+	done := make(map[string]error)
+	opts := pkg.WatchOptions{
+		Debounce:     20 * time.Millisecond,
+		QuietPeriod:  100 * time.Millisecond,
+		QueueSize:    1,
+		Polling:      true,
+		PollInterval: 20 * time.Millisecond,
+		OnResult: func(file string, report *pkg.Report, err error) {
+			mu.Lock()
+			done[file] = err
+			mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- client.Watch(ctx, opts, dir) }()
+	time.Sleep(100 * time.Millisecond)
+
+	const files = 20
+	for i := 0; i < files; i++ {
+		writeFile(t, filepath.Join(dir, fmt.Sprintf("%02d.log", i)), "data")
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := len(done)
+		mu.Unlock()
+		if n >= files || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(done) != files {
+		t.Fatalf("compressed %d of %d files", len(done), files)
+	}
+	for file, err := range done {
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+}